@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Output budgets for join (-max-tokens, -max-bytes); 0 means unlimited.
+var (
+	maxTokens int
+	maxBytes  int
+)
+
+// budgetFile is a file accepted for a budgeted join with its distance from
+// the entry files.
+type budgetFile struct {
+	path     string
+	relPath  string
+	distance int
+}
+
+// joinWithBudget writes the given files and their dependencies to w within
+// the output budget. Entry files are always written. Dependencies, followed
+// by the importers of the entry files with -importers, are written in
+// breadth-first order of their distance from the entry files until one no
+// longer fits; it and all files after it are listed in a DROPPED FILES
+// trailer instead, so a file is never dropped in favour of a farther one. The
+// magic header and anything already in w count against the budget; the
+// trailer does not.
+func joinWithBudget(files []string, processed map[string]bool, w *bytes.Buffer) {
+	var queue []budgetFile
+	accept := func(filePath string, distance int) {
+		relPath, ok, err := acceptFile(filePath, processed)
+		if err != nil {
+			log.Printf("Error processing %s: %v", filePath, err)
+		}
+		if ok {
+			queue = append(queue, budgetFile{path: filePath, relPath: relPath, distance: distance})
+		}
+	}
+	for _, file := range files {
+		accept(file, 0)
+	}
+	for i := 0; i < len(queue); i++ {
+		deps, err := fileDependencies(queue[i].path)
+		if err != nil {
+			log.Printf("Error processing %s: %v", queue[i].path, err)
+			continue
+		}
+		for _, dep := range deps {
+			accept(dep, queue[i].distance+1)
+		}
+	}
+	for distance, level := range importerLevels(files) {
+		for _, importer := range level {
+			accept(importer, distance+1)
+		}
+	}
+	// Importers were appended after all dependencies; order everything by
+	// distance, keeping the breadth-first order within a distance.
+	ordered := make([]budgetFile, 0, len(queue))
+	for distance := 0; len(ordered) < len(queue); distance++ {
+		for _, f := range queue {
+			if f.distance == distance {
+				ordered = append(ordered, f)
+			}
+		}
+	}
+
+	usedBytes := len(magicHeaderV2) + 1 + w.Len()
+	usedTokens := estimateTokens([]byte(magicHeaderV2)) + estimateTokens(w.Bytes())
+	var dropped []budgetFile
+	var section bytes.Buffer
+	for _, f := range ordered {
+		if len(dropped) > 0 {
+			dropped = append(dropped, f)
+			continue
+		}
+		section.Reset()
+		if err := writeFile(&section, f.path, f.relPath); err != nil {
+			log.Printf("Error processing %s: %v", f.path, err)
+			continue
+		}
+		sectionTokens := estimateTokens(section.Bytes())
+		fits := (maxBytes <= 0 || usedBytes+section.Len() <= maxBytes) &&
+			(maxTokens <= 0 || usedTokens+sectionTokens <= maxTokens)
+		if !fits && f.distance > 0 {
+			dropped = append(dropped, f)
+			continue
+		}
+		usedBytes += section.Len()
+		usedTokens += sectionTokens
+		w.Write(section.Bytes())
+	}
+	if len(dropped) > 0 {
+		if err := writeDroppedTrailer(w, dropped); err != nil {
+			log.Printf("Error writing dropped files: %v", err)
+		}
+		log.Printf("Budget exhausted: %d file(s) dropped", len(dropped))
+	}
+}
+
+// writeDroppedTrailer lists the files left out of a budgeted join, with the
+// size and estimated token count of the section each would have taken
+// (delimiters included, as the budget counts them) and their distance from
+// the entry files.
+func writeDroppedTrailer(w io.Writer, dropped []budgetFile) error {
+	budget := ""
+	if maxTokens > 0 {
+		budget += fmt.Sprintf(" -max-tokens=%d", maxTokens)
+	}
+	if maxBytes > 0 {
+		budget += fmt.Sprintf(" -max-bytes=%d", maxBytes)
+	}
+	if _, err := fmt.Fprintf(w, "// --------- DROPPED FILES: %d (budget:%s) ----------\n", len(dropped), budget); err != nil {
+		return err
+	}
+	for _, f := range dropped {
+		var section bytes.Buffer
+		if err := writeFile(&section, f.path, f.relPath); err != nil {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "// %q (section: %d bytes, tokens: ~%d, distance: %d)\n", f.relPath, section.Len(), estimateTokens(section.Bytes()), f.distance); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "// --------- DROPPED FILES END ----------\n")
+	return err
+}
+
+// estimateTokens approximates the number of tokens a BPE tokenizer of a
+// large language model produces for data, without needing its vocabulary:
+// runs of ASCII letters and digits and runs of a repeated ASCII symbol
+// (such as "----") count one token per four characters, non-ASCII
+// characters one token each, and whitespace runs one token unless they are a
+// single space, which is merged into the following word. Code typically
+// comes out at three to four bytes per token, erring on the high side.
+func estimateTokens(data []byte) int {
+	tokens := 0
+	for i := 0; i < len(data); {
+		r, size := utf8.DecodeRune(data[i:])
+		switch {
+		case r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'):
+			n := 0
+			for i < len(data) && data[i] < utf8.RuneSelf && (isASCIIAlnum(data[i]) || data[i] == '_') {
+				n++
+				i++
+			}
+			tokens += (n + 3) / 4
+		case unicode.IsSpace(r):
+			n := 0
+			for i < len(data) && (data[i] == ' ' || data[i] == '\t' || data[i] == '\n' || data[i] == '\r') {
+				n++
+				i++
+			}
+			if n > 1 || r != ' ' {
+				tokens++
+			}
+			if n == 0 {
+				i += size
+			}
+		case r < utf8.RuneSelf:
+			n := 0
+			for i < len(data) && data[i] == byte(r) {
+				n++
+				i++
+			}
+			tokens += (n + 3) / 4
+		default:
+			tokens++
+			i += size
+		}
+	}
+	return tokens
+}
+
+// isASCIIAlnum reports whether b is an ASCII letter or digit.
+func isASCIIAlnum(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9'
+}
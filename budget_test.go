@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+	}{
+		{"", 0},
+		{"a", 1},
+		{"abcd", 1},
+		{"abcde", 2},
+		{"foo bar", 2},
+		{"foo  bar", 3},
+		{"foo\nbar", 3},
+		{"x := y", 4},
+		{"----------", 3},
+		{"()", 2},
+		{"héllo", 3},
+		{"日本語", 3},
+		{"snake_case_name", 4},
+	}
+	for _, tt := range tests {
+		if got := estimateTokens([]byte(tt.in)); got != tt.want {
+			t.Errorf("estimateTokens(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+	// Real code comes out at roughly three to four bytes per token.
+	src, err := os.ReadFile("budget.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := estimateTokens(src); len(src) < 2*n || len(src) > 5*n {
+		t.Errorf("estimateTokens of %d bytes of code = %d", len(src), n)
+	}
+}
+
+func TestJoinWithBudget(t *testing.T) {
+	resetGoModules(t)
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"go.mod":       "module example.com/m\n",
+		"main.go":      "package main\n\nimport \"example.com/m/near\"\n\nfunc main() { near.F() }\n",
+		"near/f.go":    "package near\n\nimport \"example.com/m/far\"\n\nfunc F() { far.G() }\n",
+		"far/g.go":     "package far\n\nfunc G() {}\n" + strings.Repeat("// padding\n", 20),
+		"far/other.go": "package far\n",
+	})
+	root, tokens, limit := rootDir, maxTokens, maxBytes
+	t.Cleanup(func() { rootDir, maxTokens, maxBytes = root, tokens, limit })
+	rootDir, maxTokens = dir, 0
+	addGoModule("example.com/m", dir)
+
+	// Find the size of the unbudgeted bundle and the sections of the far
+	// package.
+	maxBytes = 0
+	var full bytes.Buffer
+	joinWithBudget([]string{filepath.Join(dir, "main.go")}, make(map[string]bool), &full)
+	farStart := strings.Index(full.String(), "// --------- FILE START: \"far/")
+	if farStart == -1 || strings.Contains(full.String(), "DROPPED") {
+		t.Fatalf("unbudgeted bundle:\n%s", full.String())
+	}
+
+	// A budget one byte short of the far package drops both of its files.
+	maxBytes = len(magicHeaderV2) + 1 + farStart
+	var budgeted bytes.Buffer
+	joinWithBudget([]string{filepath.Join(dir, "main.go")}, make(map[string]bool), &budgeted)
+	out := budgeted.String()
+	if !strings.HasPrefix(full.String(), out[:farStart]) || strings.Contains(out, "FILE START: \"far/") {
+		t.Fatalf("budgeted bundle:\n%s", out)
+	}
+	trailer := regexp.MustCompile(`// "(far/[a-z]+\.go)" \(section: (\d+) bytes, tokens: ~\d+, distance: 2\)\n`).FindAllStringSubmatch(out, -1)
+	if len(trailer) != 2 || !strings.Contains(out, "// --------- DROPPED FILES: 2 (budget: -max-bytes=") {
+		t.Fatalf("trailer does not list the far package:\n%s", out)
+	}
+	// The sizes in the trailer are those of the sections in the full bundle.
+	total := 0
+	for _, m := range trailer {
+		n, _ := strconv.Atoi(m[2])
+		total += n
+	}
+	if total != full.Len()-farStart {
+		t.Errorf("trailer sections add up to %d bytes, want %d", total, full.Len()-farStart)
+	}
+}
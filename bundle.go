@@ -0,0 +1,366 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// base64LineLength is the width at which base64 encoded bodies are wrapped.
+const base64LineLength = 76
+
+// Statuses reported for a bundleEntry.
+const (
+	statusIntact     = "intact"
+	statusUnchecked  = "unchecked"
+	statusModified   = "modified"
+	statusTruncated  = "truncated"
+	statusMissingEnd = "missing FILE END"
+	statusInvalid    = "invalid header"
+)
+
+// bundleEntry is a single file section read back from a joined stream.
+type bundleEntry struct {
+	path     string
+	size     int64
+	modTime  string
+	mode     os.FileMode
+	encoding string
+	sha256   string
+	body     []byte
+
+	// sizeMismatch is set when the body could not be framed by the size
+	// recorded in the header and the FILE END delimiter was used instead.
+	sizeMismatch bool
+	// missingEnd is set when no FILE END delimiter was found for the file.
+	missingEnd bool
+	// damage is the status of an entry whose header or encoded body could not
+	// be read; its body is not the file content and must not be written.
+	damage string
+}
+
+// writeFileSection writes the contents of filePath to w wrapped in the
+// FILE START and FILE END delimiters. The content is copied byte for byte;
+// if it does not end in a newline, a single separator newline is written
+// before the FILE END delimiter so that it starts on its own line. Binary
+// content is handled according to binaryMode.
+func writeFileSection(w io.Writer, filePath, relPath string) error {
+	data, err := os.ReadFile(filepath.Clean(filePath))
+	if err != nil {
+		return err
+	}
+	return writeFileData(w, filePath, relPath, data, "")
+}
+
+// writeFileData writes data as the section of filePath, like
+// writeFileSection. fields are additional header fields (", key: value")
+// written after the mode.
+func writeFileData(w io.Writer, filePath, relPath string, data []byte, fields string) error {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return err
+	}
+	extra := fmt.Sprintf(", mode: %04o", info.Mode().Perm()) + fields
+	size := len(data)
+	sum := sha256.Sum256(data)
+	if isBinary(data) {
+		switch binaryMode {
+		case "skip":
+			log.Printf("Skipping binary file %s", relPath)
+			return nil
+		case "encode":
+			extra += ", encoding: base64"
+			data = encodeBase64Lines(data)
+		}
+	}
+	extra += ", sha256: " + hex.EncodeToString(sum[:])
+	modTime := info.ModTime().Format(time.RFC3339)
+	if _, err := fmt.Fprintf(w, fileStartFormat, relPath, size, modTime, extra); err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if needsSeparator(data) {
+		if _, err := io.WriteString(w, "\n"); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, fileEndFormat, relPath)
+	return err
+}
+
+// isBinary reports whether data looks like binary content: it contains a NUL
+// byte or is not valid UTF-8.
+func isBinary(data []byte) bool {
+	return bytes.IndexByte(data, 0) != -1 || !utf8.Valid(data)
+}
+
+// encodeBase64Lines base64 encodes data and wraps it into newline-terminated
+// lines of base64LineLength characters.
+func encodeBase64Lines(data []byte) []byte {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	var buf bytes.Buffer
+	for len(encoded) > base64LineLength {
+		buf.WriteString(encoded[:base64LineLength])
+		buf.WriteByte('\n')
+		encoded = encoded[base64LineLength:]
+	}
+	if encoded != "" {
+		buf.WriteString(encoded)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+// status classifies entry by comparing its body with the size and checksum
+// recorded in its header.
+func (e *bundleEntry) status() string {
+	switch {
+	case e.damage != "":
+		return e.damage
+	case e.missingEnd:
+		return statusMissingEnd
+	case e.sizeMismatch && e.size >= 0 && int64(len(e.body)) < e.size:
+		return statusTruncated
+	case e.sizeMismatch:
+		return statusModified
+	case e.sha256 == "":
+		return statusUnchecked
+	}
+	sum := sha256.Sum256(e.body)
+	if hex.EncodeToString(sum[:]) != e.sha256 {
+		return statusModified
+	}
+	return statusIntact
+}
+
+// needsSeparator reports whether a newline has to be written between body
+// and the FILE END delimiter.
+func needsSeparator(body []byte) bool {
+	return len(body) == 0 || body[len(body)-1] != '\n'
+}
+
+// parseFileStart parses a FILE START delimiter line into a bundleEntry
+// without a body. If only a field is invalid, the entry parsed so far is
+// returned along with the error.
+func parseFileStart(line string) (*bundleEntry, error) {
+	rest := strings.TrimPrefix(line, fileStartPrefix)
+	if !strings.HasPrefix(rest, "\"") {
+		return nil, fmt.Errorf("invalid header format: %s", line)
+	}
+	endQuote := strings.Index(rest[1:], "\"")
+	if endQuote == -1 {
+		return nil, fmt.Errorf("invalid header format: %s", line)
+	}
+	entry := &bundleEntry{path: rest[1 : 1+endQuote], size: -1}
+	rest = rest[2+endQuote:]
+	open := strings.Index(rest, "(")
+	closing := strings.LastIndex(rest, ")")
+	if open == -1 || closing < open {
+		return entry, nil
+	}
+	for _, field := range strings.Split(rest[open+1:closing], ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(field), ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.TrimSpace(key) {
+		case "size":
+			size, err := strconv.ParseInt(strings.TrimSuffix(value, " bytes"), 10, 64)
+			if err != nil || size < 0 {
+				return entry, fmt.Errorf("invalid size in header: %s", line)
+			}
+			entry.size = size
+		case "modtime":
+			entry.modTime = value
+		case "mode":
+			mode, err := strconv.ParseUint(value, 8, 32)
+			if err != nil {
+				return entry, fmt.Errorf("invalid mode in header: %s", line)
+			}
+			entry.mode = os.FileMode(mode).Perm()
+		case "encoding":
+			entry.encoding = value
+		case "sha256":
+			entry.sha256 = strings.ToLower(value)
+		}
+	}
+	return entry, nil
+}
+
+// readBundle parses a joined stream into its file entries. Bodies are framed
+// by the size recorded in each FILE START header, which reproduces the
+// original bytes exactly. If the size does not line up with a FILE END
+// delimiter (for example because the bundle was edited by hand), the body
+// falls back to everything up to the next FILE END delimiter.
+func readBundle(r io.Reader) ([]*bundleEntry, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	line, pos := nextLine(data, 0)
+	if line == "" && pos == 0 {
+		return nil, fmt.Errorf("input is empty, missing magic header")
+	}
+	if !strings.HasPrefix(line, magicHeader) && !strings.HasPrefix(line, magicHeaderV2) {
+		return nil, fmt.Errorf("invalid magic header: %s", line)
+	}
+	var entries []*bundleEntry
+	for pos < len(data) {
+		line, pos = nextLine(data, pos)
+		if strings.HasPrefix(line, diffStartPrefix) {
+			pos = skipDiffSection(data, pos, line)
+			continue
+		}
+		if !strings.HasPrefix(line, fileStartPrefix) {
+			continue
+		}
+		entry, err := parseFileStart(line)
+		if err != nil {
+			log.Print(err)
+			if entry == nil {
+				entry = &bundleEntry{path: strings.TrimPrefix(line, fileStartPrefix), size: -1}
+			}
+			pos = frameByDelimiter(data, pos, entry)
+			entry.damage = statusInvalid
+			entries = append(entries, entry)
+			continue
+		}
+		switch entry.encoding {
+		case "":
+			if end, ok := frameBySize(data, pos, entry); ok {
+				pos = end
+			} else {
+				pos = frameByDelimiter(data, pos, entry)
+				entry.sizeMismatch = !entry.missingEnd
+			}
+		case "base64":
+			// Encoded bodies never contain delimiter lines and are decoded
+			// back to the size recorded in the header.
+			pos = frameByDelimiter(data, pos, entry)
+			decoded, err := base64.StdEncoding.DecodeString(string(bytes.Join(bytes.Fields(entry.body), nil)))
+			if err != nil {
+				log.Printf("Invalid base64 content for %q: %v", entry.path, err)
+				entry.damage = statusModified
+				break
+			}
+			entry.body = decoded
+			entry.sizeMismatch = entry.size >= 0 && int64(len(decoded)) != entry.size
+		default:
+			log.Printf("Unsupported encoding %q for %q", entry.encoding, entry.path)
+			pos = frameByDelimiter(data, pos, entry)
+			entry.damage = statusInvalid
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// frameBySize reads entry.size bytes of body starting at pos and checks that
+// the FILE END delimiter for the entry follows. It returns the position just
+// past the delimiter.
+func frameBySize(data []byte, pos int, entry *bundleEntry) (int, bool) {
+	if entry.size < 0 || int64(len(data)-pos) < entry.size {
+		return 0, false
+	}
+	end := pos + int(entry.size)
+	body := data[pos:end]
+	endLine := strings.TrimSuffix(fmt.Sprintf(fileEndFormat, entry.path), "\n")
+	if !bytes.HasPrefix(data[end:], []byte(endLine)) {
+		if !needsSeparator(body) || !bytes.HasPrefix(data[end:], []byte("\n"+endLine)) {
+			return 0, false
+		}
+		end++
+	}
+	end += len(endLine)
+	if end < len(data) {
+		if data[end] != '\n' {
+			return 0, false
+		}
+		end++
+	}
+	entry.body = body
+	return end, true
+}
+
+// frameByDelimiter takes everything from pos up to the FILE END line for
+// entry as its body. Only the delimiter carrying the entry's own path ends the
+// body, so delimiter lines that belong to the file's content are kept. It
+// returns the position just past that line. If the FILE END is missing, the
+// body runs up to the next FILE START line and that line's position is
+// returned.
+func frameByDelimiter(data []byte, pos int, entry *bundleEntry) int {
+	endLine := strings.TrimSuffix(fmt.Sprintf(fileEndFormat, entry.path), "\n")
+	start := pos
+	nextStart := -1
+	for pos < len(data) {
+		lineStart := pos
+		var line string
+		line, pos = nextLine(data, pos)
+		if line == endLine {
+			entry.body = data[start:lineStart]
+			return pos
+		}
+		if nextStart == -1 && strings.HasPrefix(line, fileStartPrefix) {
+			nextStart = lineStart
+		}
+	}
+	entry.missingEnd = true
+	if nextStart == -1 {
+		nextStart = len(data)
+	}
+	entry.body = data[start:nextStart]
+	return nextStart
+}
+
+// skipDiffSection returns the position just past the DIFF section whose
+// header line was read before pos. The body is framed by the size in the
+// header, falling back to the DIFF END line, so that diff content never
+// produces file entries.
+func skipDiffSection(data []byte, pos int, line string) int {
+	endLine := strings.TrimSuffix(diffEnd, "\n")
+	if _, rest, ok := strings.Cut(line, "(size: "); ok {
+		if digits, _, ok := strings.Cut(rest, " bytes"); ok {
+			if size, err := strconv.Atoi(digits); err == nil && size >= 0 && size <= len(data)-pos {
+				end := pos + size
+				if needsSeparator(data[pos:end]) && bytes.HasPrefix(data[end:], []byte("\n"+endLine)) {
+					end++
+				}
+				if bytes.HasPrefix(data[end:], []byte(endLine)) {
+					_, next := nextLine(data, end)
+					return next
+				}
+			}
+		}
+	}
+	for pos < len(data) {
+		var l string
+		l, pos = nextLine(data, pos)
+		if l == endLine {
+			return pos
+		}
+	}
+	return pos
+}
+
+// nextLine returns the line starting at pos without its line terminator, and
+// the position of the following line.
+func nextLine(data []byte, pos int) (string, int) {
+	i := bytes.IndexByte(data[pos:], '\n')
+	if i == -1 {
+		return strings.TrimSuffix(string(data[pos:]), "\r"), len(data)
+	}
+	return strings.TrimSuffix(string(data[pos:pos+i]), "\r"), pos + i + 1
+}
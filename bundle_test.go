@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// joinFiles writes files below a temporary directory and bundles them with
+// writeFileSection, in the order given by names.
+func joinFiles(t *testing.T, names []string, files map[string][]byte) []byte {
+	t.Helper()
+	src := t.TempDir()
+	var buf bytes.Buffer
+	buf.WriteString(magicHeaderV2 + "\n")
+	for _, name := range names {
+		p := filepath.Join(src, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, files[name], 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := writeFileSection(&buf, p, name); err != nil {
+			t.Fatalf("writeFileSection(%q): %v", name, err)
+		}
+	}
+	return buf.Bytes()
+}
+
+// splitBundle splits bundle into a temporary directory and returns it.
+func splitBundle(t *testing.T, bundle []byte, strict bool) (string, error) {
+	t.Helper()
+	out := t.TempDir()
+	return out, splitInput(bytes.NewReader(bundle), out, strict, false)
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string][]byte
+	}{
+		{"lf", map[string][]byte{"a.txt": []byte("one\ntwo\n")}},
+		{"crlf", map[string][]byte{"a.txt": []byte("one\r\ntwo\r\n")}},
+		{"mixed line endings", map[string][]byte{"a.txt": []byte("one\r\ntwo\nthree\r")}},
+		{"no final newline", map[string][]byte{"a.txt": []byte("one\ntwo")}},
+		{"only newlines", map[string][]byte{"a.txt": []byte("\n\n")}},
+		{"empty", map[string][]byte{"a.txt": {}}},
+		{"huge single line", map[string][]byte{"a.txt": bytes.Repeat([]byte("x"), 4<<20)}},
+		{"huge single line with newline", map[string][]byte{"a.txt": append(bytes.Repeat([]byte("y"), 1<<20), '\n')}},
+		{"trailing whitespace", map[string][]byte{"a.txt": []byte("one  \n\t\n  ")}},
+		{"several files", map[string][]byte{
+			"a.txt":     []byte("one\r\n"),
+			"dir/b.txt": {},
+			"dir/c.txt": []byte("no newline"),
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var names []string
+			for name := range tt.files {
+				names = append(names, name)
+			}
+			bundle := joinFiles(t, names, tt.files)
+			out, err := splitBundle(t, bundle, true)
+			if err != nil {
+				t.Fatalf("split: %v", err)
+			}
+			for name, want := range tt.files {
+				got, err := os.ReadFile(filepath.Join(out, filepath.FromSlash(name)))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, want) {
+					t.Errorf("%s: got %d bytes %q..., want %d bytes %q...",
+						name, len(got), truncate(got), len(want), truncate(want))
+				}
+			}
+		})
+	}
+}
+
+func TestEveryLineEndsWithNewline(t *testing.T) {
+	bundle := joinFiles(t, []string{"a.txt", "b.txt"}, map[string][]byte{
+		"a.txt": []byte("no newline"),
+		"b.txt": {},
+	})
+	for _, line := range strings.SplitAfter(string(bundle), "\n") {
+		if line != "" && !strings.HasSuffix(line, "\n") {
+			t.Errorf("line %q does not end with a newline", line)
+		}
+	}
+	if !strings.Contains(string(bundle), "no newline\n// --------- FILE END: \"a.txt\"") {
+		t.Errorf("FILE END does not start on its own line:\n%s", bundle)
+	}
+}
+
+// truncate shortens data for error messages.
+func truncate(data []byte) []byte {
+	if len(data) > 40 {
+		return data[:40]
+	}
+	return data
+}
+
+func TestRoundTripOwnSource(t *testing.T) {
+	// main.go contains the delimiter formats and prefixes themselves.
+	src, err := os.ReadFile("main.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string][]byte{"main.go": src, "after.go": []byte("package main\n")}
+	bundle := joinFiles(t, []string{"main.go", "after.go"}, files)
+	out, err := splitBundle(t, bundle, true)
+	if err != nil {
+		t.Fatalf("split: %v", err)
+	}
+	for name, want := range files {
+		got, err := os.ReadFile(filepath.Join(out, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s does not round trip", name)
+		}
+	}
+}
+
+func TestHandEditedBodyWithDelimiters(t *testing.T) {
+	body := "before\n// --------- FILE END: \"other.go\" ----------\n" +
+		"// --------- FILE START: \"other.go\" (size: 1 bytes) ----------\nafter\n"
+	bundle := joinFiles(t, []string{"a.txt", "b.txt"}, map[string][]byte{
+		"a.txt": []byte(body),
+		"b.txt": []byte("b\n"),
+	})
+	// Editing the body invalidates its size, so the FILE END delimiter frames it.
+	edited := bytes.Replace(bundle, []byte("before\n"), []byte("before, edited\n"), 1)
+	entries, err := readBundle(bytes.NewReader(edited))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+	want := strings.Replace(body, "before\n", "before, edited\n", 1)
+	if string(entries[0].body) != want {
+		t.Errorf("body = %q, want %q", entries[0].body, want)
+	}
+	if got := entries[0].status(); got != statusModified {
+		t.Errorf("status = %q, want %q", got, statusModified)
+	}
+	if got := entries[1].status(); got != statusIntact {
+		t.Errorf("status of b.txt = %q, want %q", got, statusIntact)
+	}
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	binary := make([]byte, 1000)
+	for i := range binary {
+		binary[i] = byte(i * 7)
+	}
+	files := map[string][]byte{
+		"bin.dat":  binary,
+		"nul.txt":  []byte("a\x00b\n"),
+		"utf8.txt": []byte("héllo wörld\n"),
+	}
+	bundle := joinFiles(t, []string{"bin.dat", "nul.txt", "utf8.txt"}, files)
+	if !bytes.Contains(bundle, []byte(`"bin.dat" (size: 1000 bytes`)) ||
+		bytes.Count(bundle, []byte("encoding: base64")) != 2 {
+		t.Errorf("binary files are not base64 encoded:\n%s", bundle)
+	}
+	out, err := splitBundle(t, bundle, true)
+	if err != nil {
+		t.Fatalf("split: %v", err)
+	}
+	for name, want := range files {
+		got, err := os.ReadFile(filepath.Join(out, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s does not round trip", name)
+		}
+	}
+}
+
+func TestBinarySkip(t *testing.T) {
+	defer func(mode string) { binaryMode = mode }(binaryMode)
+	binaryMode = "skip"
+	bundle := joinFiles(t, []string{"bin.dat", "a.txt"}, map[string][]byte{
+		"bin.dat": {0, 1, 2},
+		"a.txt":   []byte("a\n"),
+	})
+	entries, err := readBundle(bytes.NewReader(bundle))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].path != "a.txt" {
+		t.Errorf("got %d entries, want only a.txt", len(entries))
+	}
+}
+
+func TestDamagedEntries(t *testing.T) {
+	bundle := joinFiles(t, []string{"bin.dat", "a.txt", "b.txt"}, map[string][]byte{
+		"bin.dat": bytes.Repeat([]byte{0, 1, 2, 3}, 100),
+		"a.txt":   []byte("a\n"),
+		"b.txt":   []byte("b\n"),
+	})
+	tests := []struct {
+		name       string
+		old, new   string
+		path, want string
+	}{
+		{"corrupt base64", "AAECAwABAgMAAQID", "AAECAwABAgMAAQ!!", "bin.dat", statusModified},
+		{"invalid size", `"a.txt" (size: 2 bytes`, `"a.txt" (size: x bytes`, "a.txt", statusInvalid},
+		{"invalid mode", `"b.txt" (size: 2 bytes, modtime: `, `"b.txt" (size: 2 bytes, mode: 9z, modtime: `, "b.txt", statusInvalid},
+		{"unsupported encoding", "encoding: base64", "encoding: base32", "bin.dat", statusInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !bytes.Contains(bundle, []byte(tt.old)) {
+				t.Fatalf("bundle does not contain %q", tt.old)
+			}
+			damaged := bytes.Replace(bundle, []byte(tt.old), []byte(tt.new), 1)
+			var report bytes.Buffer
+			ok, err := verifyInput(bytes.NewReader(damaged), &report)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok {
+				t.Errorf("verify passed:\n%s", report.String())
+			}
+			if !strings.Contains(report.String(), fmt.Sprintf("%-18s %s\n", tt.want, tt.path)) {
+				t.Errorf("report does not list %s as %s:\n%s", tt.path, tt.want, report.String())
+			}
+			out, err := splitBundle(t, damaged, true)
+			if err == nil {
+				t.Error("strict split succeeded")
+			}
+			if _, err := os.Stat(filepath.Join(out, "a.txt")); err == nil {
+				t.Error("strict split wrote files")
+			}
+		})
+	}
+}
+
+func TestSplitPreservesModeAndModTime(t *testing.T) {
+	src := t.TempDir()
+	script := filepath.Join(src, "build.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(script, 0750); err != nil {
+		t.Fatal(err)
+	}
+	modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := os.Chtimes(script, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	buf.WriteString(magicHeaderV2 + "\n")
+	if err := writeFileSection(&buf, script, "build.sh"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "mode: 0750") {
+		t.Errorf("header does not record the mode:\n%s", buf.String())
+	}
+	for _, preserve := range []bool{true, false} {
+		out := t.TempDir()
+		if err := splitInput(bytes.NewReader(buf.Bytes()), out, true, preserve); err != nil {
+			t.Fatal(err)
+		}
+		info, err := os.Stat(filepath.Join(out, "build.sh"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := info.ModTime().Equal(modTime); got != preserve {
+			t.Errorf("preserve=%v: modtime = %v", preserve, info.ModTime())
+		}
+		if got := info.Mode().Perm() == 0750; got != preserve {
+			t.Errorf("preserve=%v: mode = %v", preserve, info.Mode().Perm())
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+func init() {
+	registerResolver(cResolver{})
+}
+
+// includeDirs are the include directories (-I) searched for C/C++ headers and
+// imported .proto files.
+var includeDirs []string
+
+// cIncludeRegex matches #include directives with quoted or angle-bracket
+// header names.
+var cIncludeRegex = regexp.MustCompile(`(?m)^\s*#\s*include\s*([<"])([^>"\n]+)[>"]`)
+
+// cResolver follows the #include directives of C and C++ files to project
+// headers.
+type cResolver struct{}
+
+// Extensions implements Resolver.
+func (cResolver) Extensions() []string {
+	return []string{".c", ".h", ".cc", ".cpp", ".cxx", ".c++", ".hh", ".hpp", ".hxx", ".h++", ".inl", ".ipp"}
+}
+
+// Dependencies implements Resolver. As with a compiler, quoted headers are
+// looked up relative to the including file first and then in the include
+// directories; angle-bracket headers only in the include directories, so
+// system headers are never followed.
+func (cResolver) Dependencies(filePath string, data []byte) ([]string, error) {
+	var files []string
+	for _, m := range cIncludeRegex.FindAllSubmatch(data, -1) {
+		name := filepath.FromSlash(string(m[2]))
+		var dirs []string
+		if string(m[1]) == `"` {
+			dirs = append(dirs, filepath.Dir(filePath))
+		}
+		for _, dir := range append(dirs, includeDirs...) {
+			if p := filepath.Join(dir, name); isRegularFile(p) {
+				files = appendUnique(files, p)
+				break
+			}
+		}
+	}
+	return files, nil
+}
+
+// parseIncludeDirs parses the comma-separated -I value into directories
+// relative to the project root.
+func parseIncludeDirs(value string) []string {
+	var dirs []string
+	for _, dir := range strings.Split(value, ",") {
+		if dir = strings.TrimSpace(dir); dir != "" {
+			dirs = append(dirs, resolveModulePath(rootDir, dir))
+		}
+	}
+	return dirs
+}
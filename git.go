@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// runGit runs git with the given arguments in the project root and returns
+// its standard output.
+func runGit(args ...string) ([]byte, error) {
+	cmd := exec.Command("git", append([]string{"-C", rootDir}, args...)...) // #nosec G204
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git %s: %s", strings.Join(args, " "), msg)
+		}
+		return nil, fmt.Errorf("git %s: %v", strings.Join(args, " "), err)
+	}
+	return out, nil
+}
+
+// changedSinceBase returns the commit the changes since ref are computed
+// against: the merge base of ref and HEAD, so that commits made on ref after
+// the branch point do not count as changes.
+func changedSinceBase(ref string) (string, error) {
+	out, err := runGit("merge-base", ref, "HEAD")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// changedFiles returns the files below the project root that were added or
+// modified since base, including uncommitted changes in the working tree and
+// new untracked files that are not ignored. Deleted files are left out.
+func changedFiles(base string) ([]string, error) {
+	out, err := runGit("diff", "--name-only", "-z", "--relative", "--diff-filter=d", "--no-renames", base)
+	if err != nil {
+		return nil, err
+	}
+	untracked, err := runGit("ls-files", "--others", "--exclude-standard", "-z")
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, name := range strings.Split(string(out)+string(untracked), "\x00") {
+		if name != "" {
+			files = append(files, filepath.Join(rootDir, filepath.FromSlash(name)))
+		}
+	}
+	return files, nil
+}
+
+// writeDiffSection writes the unified diff of the changes since base as a
+// DIFF section. The section is not a file: split and verify skip it.
+func writeDiffSection(w io.Writer, ref, base string) error {
+	diff, err := runGit("diff", "--no-color", "--no-ext-diff", "--relative", base)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, diffStartFormat, ref, len(diff)); err != nil {
+		return err
+	}
+	if _, err := w.Write(diff); err != nil {
+		return err
+	}
+	if needsSeparator(diff) {
+		if _, err := io.WriteString(w, "\n"); err != nil {
+			return err
+		}
+	}
+	_, err = io.WriteString(w, diffEnd)
+	return err
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// gitRepo creates a git repository with an initial commit of files.
+func gitRepo(t *testing.T, files map[string]string) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	dir := t.TempDir()
+	writeTree(t, dir, files)
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "-A"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "initial"},
+	} {
+		cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+		}
+	}
+	return dir
+}
+
+func TestChangedFiles(t *testing.T) {
+	dir := gitRepo(t, map[string]string{
+		".gitignore":       "*.log\n",
+		"svc/go.mod":       "module svc\n",
+		"svc/modified.go":  "package svc\n",
+		"svc/deleted.go":   "package svc\n",
+		"svc/unchanged.go": "package svc\n",
+		"other/outside.go": "package other\n",
+	})
+	writeTree(t, dir, map[string]string{
+		"svc/modified.go":        "package svc // changed\n",
+		"svc/shared/util/new.go": "package util\n",
+		"svc/debug.log":          "ignored\n",
+		"other/new.go":           "package other\n",
+	})
+	if err := os.Remove(filepath.Join(dir, "svc", "deleted.go")); err != nil {
+		t.Fatal(err)
+	}
+	root := rootDir
+	t.Cleanup(func() { rootDir = root })
+	rootDir = filepath.Join(dir, "svc")
+	base, err := changedSinceBase("HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := changedFiles(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range files {
+		rel, _ := filepath.Rel(rootDir, f)
+		got = append(got, filepath.ToSlash(rel))
+	}
+	sort.Strings(got)
+	if want := []string{"modified.go", "shared/util/new.go"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("changedFiles = %v, want %v", got, want)
+	}
+}
+
+func TestDiffSectionIsSkipped(t *testing.T) {
+	dir := gitRepo(t, map[string]string{"a.go": "package a\n"})
+	// The diff itself contains delimiter lines.
+	writeTree(t, dir, map[string]string{"a.go": "package a\n// --------- FILE START: \"x\" (size: 1 bytes) ----------\n"})
+	root := rootDir
+	t.Cleanup(func() { rootDir = root })
+	rootDir = dir
+	var buf strings.Builder
+	buf.WriteString(magicHeaderV2 + "\n")
+	if err := writeDiffSection(&buf, "HEAD", "HEAD"); err != nil {
+		t.Fatal(err)
+	}
+	bundle := []byte(buf.String())
+	bundle = append(bundle, joinFiles(t, []string{"b.txt"}, map[string][]byte{"b.txt": []byte("b\n")})[len(magicHeaderV2)+1:]...)
+	var report strings.Builder
+	ok, err := verifyInput(strings.NewReader(string(bundle)), &report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok || !strings.Contains(report.String(), "1 file(s): 1 intact") {
+		t.Errorf("verify of a bundle with a DIFF section:\n%s", report.String())
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// matchPattern reports whether name matches the glob pattern. Both use
+// forward slashes as separators. Besides the syntax of path.Match, a "**"
+// path segment matches zero or more directories.
+func matchPattern(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+// matchSegments matches path segments against pattern segments, expanding
+// "**" segments.
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			pattern = pattern[1:]
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if match, err := path.Match(pattern[0], name[0]); err != nil || !match {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// hasMeta reports whether s contains glob metacharacters.
+func hasMeta(s string) bool {
+	return strings.ContainsAny(s, "*?[")
+}
+
+// expandArgument expands a join argument into the files it names. Plain glob
+// patterns are expanded with filepath.Glob, patterns containing "**" are
+// matched against every file below their static prefix, and directories are
+// walked recursively.
+func expandArgument(pattern string) ([]string, error) {
+	pattern = filepath.Clean(pattern)
+	var matches []string
+	if strings.Contains(pattern, "**") {
+		var err error
+		matches, err = expandDoublestar(pattern)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		var err error
+		matches, err = filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var files []string
+	for _, match := range matches {
+		info, err := os.Stat(match)
+		if err != nil || !info.IsDir() {
+			files = append(files, filepath.Clean(match))
+			continue
+		}
+		dirFiles, err := walkFiles(match)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, dirFiles...)
+	}
+	return files, nil
+}
+
+// expandDoublestar returns the files matching a pattern that contains "**".
+func expandDoublestar(pattern string) ([]string, error) {
+	slashPattern := filepath.ToSlash(pattern)
+	segments := strings.Split(slashPattern, "/")
+	var base []string
+	for _, segment := range segments {
+		if hasMeta(segment) {
+			break
+		}
+		base = append(base, segment)
+	}
+	root := strings.Join(base, "/")
+	if root == "" {
+		if strings.HasPrefix(slashPattern, "/") {
+			root = "/"
+		} else {
+			root = "."
+		}
+	}
+	if _, err := os.Stat(filepath.FromSlash(root)); err != nil {
+		return nil, nil
+	}
+	files, err := walkFiles(filepath.FromSlash(root))
+	if err != nil {
+		return nil, err
+	}
+	var matches []string
+	for _, file := range files {
+		if matchPattern(slashPattern, filepath.ToSlash(file)) {
+			matches = append(matches, file)
+		}
+	}
+	return matches, nil
+}
+
+// walkFiles returns every regular file below dir in lexical order, skipping
+// .git directories, directories matched by the ignore files and directories
+// that cannot be read.
+func walkFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			log.Printf("Warning: skipping %s: %v", p, err)
+			return nil
+		}
+		if d.IsDir() {
+			if p != dir && (d.Name() == ".git" || isIgnored(p, true)) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Type().IsRegular() {
+			files = append(files, filepath.Clean(p))
+		}
+		return nil
+	})
+	return files, err
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "pkg/main.go", false},
+		{"pkg/*.go", "pkg/main.go", true},
+		{"**", "a/b/c", true},
+		{"**/*.go", "main.go", true},
+		{"**/*.go", "a/b/main.go", true},
+		{"**/*.go", "a/b/main.txt", false},
+		{"vendor/**", "vendor/x/y.go", true},
+		{"vendor/**", "src/vendor/y.go", false},
+		{"**/testdata/**", "testdata/a", true},
+		{"**/testdata/**", "a/b/testdata/c/d", true},
+		{"**/testdata/**", "a/testdatax/c", false},
+		{"a/**/b", "a/b", true},
+		{"a/**/b", "a/x/y/b", true},
+		{"a/**/b", "a/x/y/c", false},
+		{"a/?.go", "a/x.go", true},
+		{"a/[xy].go", "a/z.go", false},
+		{"[", "[", false},
+	}
+	for _, tt := range tests {
+		if got := matchPattern(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("matchPattern(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestExpandArgument(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.go", "b.txt", "sub/c.go", "sub/deep/d.go", ".git/config"} {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	join := func(names ...string) []string {
+		var paths []string
+		for _, name := range names {
+			paths = append(paths, filepath.Join(dir, filepath.FromSlash(name)))
+		}
+		return paths
+	}
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{"*.go", join("a.go")},
+		{"sub", join("sub/c.go", "sub/deep/d.go")},
+		{"**/*.go", join("a.go", "sub/c.go", "sub/deep/d.go")},
+		{"sub/**/d.go", join("sub/deep/d.go")},
+		{".", join("a.go", "b.txt", "sub/c.go", "sub/deep/d.go")},
+		{"missing/**", nil},
+	}
+	for _, tt := range tests {
+		got, err := expandArgument(filepath.Join(dir, tt.pattern))
+		if err != nil {
+			t.Fatalf("expandArgument(%q): %v", tt.pattern, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("expandArgument(%q) = %v, want %v", tt.pattern, got, tt.want)
+		}
+	}
+}
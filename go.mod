@@ -3,3 +3,5 @@ module github.com/ryancopley/gocat
 go 1.24.0
 
 require github.com/Masterminds/semver/v3 v3.1.1
+
+require golang.org/x/mod v0.27.0
@@ -0,0 +1,407 @@
+package main
+
+import (
+	"go/build"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+)
+
+// goModules maps Go module paths to their directories. It is filled from
+// go.work, go.mod files below the project root (including their local
+// replace directives) and -go-base, and is used to resolve imports to package
+// directories.
+var goModules = make(map[string]string)
+
+var (
+	// goRequires maps required module paths to the module version providing
+	// them (after non-local replace directives). The first go.mod to require
+	// a module wins, so the main modules' versions take precedence.
+	goRequires = make(map[string]module.Version)
+
+	// Module patterns of external dependencies to follow (-external), and
+	// the maximum number of import hops into external packages (-max-depth).
+	externalPatterns []string
+	maxExternalDepth int
+
+	// externalDepth records, per absolute package directory, how many import
+	// hops away from the project's own code an external package is.
+	externalDepth = make(map[string]int)
+)
+
+func init() {
+	registerResolver(goResolver{})
+}
+
+// goResolver follows the imports of Go files to the buildable files of the
+// imported packages of the registered modules and, with -external, of
+// allowed third-party modules.
+type goResolver struct{}
+
+// Extensions implements Resolver.
+func (goResolver) Extensions() []string {
+	return []string{".go"}
+}
+
+// Dependencies implements Resolver.
+func (goResolver) Dependencies(filePath string, data []byte) ([]string, error) {
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	parsed, err := parser.ParseFile(fset, filePath, data, parser.ImportsOnly)
+	if err != nil {
+		return nil, err
+	}
+	depth := externalDepth[filepath.Dir(absPath)]
+	var files []string
+	for _, imp := range parsed.Imports {
+		importPath, err := strconv.Unquote(imp.Path.Value)
+		if err != nil {
+			continue
+		}
+		packageDir, ok := followGoImport(importPath, depth)
+		if !ok {
+			continue
+		}
+		entries, err := os.ReadDir(packageDir)
+		if err != nil {
+			log.Printf("Error reading directory %q: %v", packageDir, err)
+			continue
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || !isBuildableGoFile(packageDir, entry.Name()) {
+				continue
+			}
+			if fileInPkg := filepath.Join(packageDir, entry.Name()); shakeKeepFile(fileInPkg) {
+				files = append(files, fileInPkg)
+			}
+		}
+	}
+	return files, nil
+}
+
+// followGoImport returns the directory of an imported package that join
+// follows from a package at the given number of import hops into external
+// code: a package of a registered module, or an allowed external package
+// while depth is below -max-depth.
+func followGoImport(importPath string, depth int) (string, bool) {
+	if packageDir, ok := resolveGoImport(importPath); ok {
+		return packageDir, true
+	}
+	if depth >= maxExternalDepth {
+		return "", false
+	}
+	packageDir, ok := resolveExternalImport(importPath)
+	if !ok {
+		return "", false
+	}
+	setExternalDepth(packageDir, depth+1)
+	return packageDir, true
+}
+
+// loadGoModules registers the modules used by a go.work file in root and
+// every go.mod file found below root, together with the local directories
+// their replace directives point at. It only fails if the go.work file
+// cannot be read or parsed.
+func loadGoModules(root string) error {
+	if work, err := readGoWork(filepath.Join(root, "go.work")); err == nil {
+		for _, use := range work.Use {
+			dir := filepath.Join(root, filepath.FromSlash(use.Path))
+			if f, err := readGoMod(filepath.Join(dir, "go.mod")); err == nil {
+				addGoModFile(f, dir)
+			}
+		}
+		addGoReplaces(work.Replace, root)
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	return filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Unreadable directories are skipped rather than failing the walk.
+			log.Printf("Warning: skipping %s: %v", p, err)
+			return nil
+		}
+		if d.IsDir() {
+			if p != root && skipGoDir(p, d.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Name() != "go.mod" {
+			return nil
+		}
+		f, err := readGoMod(p)
+		if err != nil {
+			log.Printf("Warning: unable to parse %s: %v", p, err)
+			return nil
+		}
+		addGoModFile(f, filepath.Dir(p))
+		return nil
+	})
+}
+
+// readGoMod parses the go.mod file at goModPath.
+func readGoMod(goModPath string) (*modfile.File, error) {
+	goModPath = filepath.Clean(goModPath)
+	data, err := os.ReadFile(goModPath)
+	if err != nil {
+		return nil, err
+	}
+	return modfile.Parse(goModPath, data, nil)
+}
+
+// readGoWork parses the go.work file at goWorkPath.
+func readGoWork(goWorkPath string) (*modfile.WorkFile, error) {
+	goWorkPath = filepath.Clean(goWorkPath)
+	data, err := os.ReadFile(goWorkPath)
+	if err != nil {
+		return nil, err
+	}
+	return modfile.ParseWork(goWorkPath, data, nil)
+}
+
+// addGoModFile registers the module declared by a go.mod file located in dir
+// and the local targets of its replace directives.
+func addGoModFile(f *modfile.File, dir string) {
+	if f.Module != nil {
+		addGoModule(f.Module.Mod.Path, dir)
+	}
+	addGoReplaces(f.Replace, dir)
+	addGoRequires(f)
+}
+
+// addGoRequires records the module versions required by a go.mod file,
+// applying its non-local replace directives.
+func addGoRequires(f *modfile.File) {
+	replaced := make(map[string]module.Version)
+	for _, r := range f.Replace {
+		if !modfile.IsDirectoryPath(r.New.Path) {
+			replaced[r.Old.Path] = r.New
+		}
+	}
+	for _, req := range f.Require {
+		if _, ok := goRequires[req.Mod.Path]; ok {
+			continue
+		}
+		if r, ok := replaced[req.Mod.Path]; ok {
+			goRequires[req.Mod.Path] = r
+		} else {
+			goRequires[req.Mod.Path] = req.Mod
+		}
+	}
+}
+
+// addGoReplaces registers the modules of replace directives that point at
+// local directories. Relative targets are resolved against dir, the
+// directory of the go.mod or go.work file.
+func addGoReplaces(replaces []*modfile.Replace, dir string) {
+	for _, r := range replaces {
+		if !modfile.IsDirectoryPath(r.New.Path) {
+			continue
+		}
+		target := filepath.FromSlash(r.New.Path)
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(dir, target)
+		}
+		if _, err := os.Stat(filepath.Join(target, "go.mod")); err != nil {
+			log.Printf("Warning: replacement directory %s for %s has no go.mod", target, r.Old.Path)
+			continue
+		}
+		addGoModule(r.Old.Path, target)
+	}
+}
+
+// skipGoDir reports whether a directory is ignored when looking for modules.
+// Like the go command, it skips testdata, vendor and directories starting
+// with "." or "_", as well as directories matched by the ignore files.
+func skipGoDir(p, name string) bool {
+	switch {
+	case name == "testdata", name == "vendor", name == "node_modules":
+		return true
+	case strings.HasPrefix(name, "."), strings.HasPrefix(name, "_"):
+		return true
+	}
+	return isIgnored(p, true)
+}
+
+// addGoModule registers a module directory. The first registration of a
+// module path wins, so modules listed in go.work take precedence.
+func addGoModule(name, dir string) {
+	if _, ok := goModules[name]; ok {
+		return
+	}
+	goModules[name] = filepath.Clean(dir)
+}
+
+// setGoBase registers name (-go-base) as the module path of dir in place of
+// the path declared by dir's go.mod, so that imports of that path are no
+// longer followed.
+func setGoBase(name, dir string) {
+	dir = filepath.Clean(dir)
+	if f, err := readGoMod(filepath.Join(dir, "go.mod")); err == nil && f.Module != nil && goModules[f.Module.Mod.Path] == dir {
+		delete(goModules, f.Module.Mod.Path)
+	}
+	goModules[name] = dir
+}
+
+// resolveGoImport returns the directory of the package with the given import
+// path, using the longest matching module path.
+func resolveGoImport(importPath string) (string, bool) {
+	best := ""
+	for name := range goModules {
+		if importPath != name && !strings.HasPrefix(importPath, name+"/") {
+			continue
+		}
+		if len(name) > len(best) {
+			best = name
+		}
+	}
+	if best == "" {
+		return "", false
+	}
+	relDir := strings.TrimPrefix(strings.TrimPrefix(importPath, best), "/")
+	return filepath.Clean(filepath.Join(goModules[best], filepath.FromSlash(relDir))), true
+}
+
+// resolveExternalImport returns the directory of a third-party package allowed
+// by -external. The package is looked up in the vendor directories of the
+// registered modules first and then in the module cache; the network is
+// never used.
+func resolveExternalImport(importPath string) (string, bool) {
+	first, _, _ := strings.Cut(importPath, "/")
+	if !strings.Contains(first, ".") || !isExternalAllowed(importPath) {
+		return "", false
+	}
+	modDirs := make([]string, 0, len(goModules))
+	for _, dir := range goModules {
+		modDirs = append(modDirs, dir)
+	}
+	sort.Strings(modDirs)
+	for _, dir := range modDirs {
+		vendorDir := filepath.Join(dir, "vendor", filepath.FromSlash(importPath))
+		if info, err := os.Stat(vendorDir); err == nil && info.IsDir() {
+			return vendorDir, true
+		}
+	}
+	best := ""
+	for name := range goRequires {
+		if (importPath == name || strings.HasPrefix(importPath, name+"/")) && len(name) > len(best) {
+			best = name
+		}
+	}
+	if best == "" {
+		return "", false
+	}
+	modDir, err := moduleCacheDir(goRequires[best])
+	if err != nil {
+		log.Printf("Warning: unable to locate %s in the module cache: %v", best, err)
+		return "", false
+	}
+	relDir := strings.TrimPrefix(strings.TrimPrefix(importPath, best), "/")
+	packageDir := filepath.Join(modDir, filepath.FromSlash(relDir))
+	if info, err := os.Stat(packageDir); err != nil || !info.IsDir() {
+		log.Printf("Warning: %s not found in the module cache (run go mod download)", importPath)
+		return "", false
+	}
+	// Requirements of the dependency let its own imports be resolved too.
+	if f, err := readGoModLax(filepath.Join(modDir, "go.mod")); err == nil {
+		addGoRequires(f)
+	}
+	return packageDir, true
+}
+
+// isExternalAllowed reports whether importPath, or one of the modules it may
+// belong to, matches a pattern given via -external.
+func isExternalAllowed(importPath string) bool {
+	for _, pattern := range externalPatterns {
+		if matchPattern(pattern, importPath) || matchPattern(pattern+"/**", importPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// setExternalDepth records the import distance of an external package
+// directory, keeping the shortest distance seen.
+func setExternalDepth(dir string, depth int) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return
+	}
+	if d, ok := externalDepth[absDir]; !ok || depth < d {
+		externalDepth[absDir] = depth
+	}
+}
+
+// readGoModLax parses the go.mod file of a dependency.
+func readGoModLax(goModPath string) (*modfile.File, error) {
+	goModPath = filepath.Clean(goModPath)
+	data, err := os.ReadFile(goModPath)
+	if err != nil {
+		return nil, err
+	}
+	return modfile.ParseLax(goModPath, data, nil)
+}
+
+// goModCache returns the module cache directory: GOMODCACHE, or pkg/mod in
+// the first GOPATH entry.
+func goModCache() string {
+	if dir := os.Getenv("GOMODCACHE"); dir != "" {
+		return dir
+	}
+	gopath := filepath.SplitList(build.Default.GOPATH)
+	if len(gopath) == 0 {
+		return ""
+	}
+	return filepath.Join(gopath[0], "pkg", "mod")
+}
+
+// moduleCacheDir returns the directory of a module version in the module cache.
+func moduleCacheDir(mod module.Version) (string, error) {
+	escPath, err := module.EscapePath(mod.Path)
+	if err != nil {
+		return "", err
+	}
+	escVersion, err := module.EscapeVersion(mod.Version)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(goModCache(), escPath+"@"+escVersion), nil
+}
+
+// moduleCachePath maps a file inside the module cache to vendor/<module>/<file>,
+// the path it would have in a vendor directory.
+func moduleCachePath(absPath string) (string, bool) {
+	cache := goModCache()
+	if cache == "" {
+		return "", false
+	}
+	rel, err := filepath.Rel(cache, absPath)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return "", false
+	}
+	escPath, rest, ok := strings.Cut(filepath.ToSlash(rel), "@")
+	if !ok {
+		return "", false
+	}
+	modPath, err := module.UnescapePath(escPath)
+	if err != nil {
+		return "", false
+	}
+	_, file, ok := strings.Cut(rest, "/")
+	if !ok {
+		return "", false
+	}
+	return filepath.FromSlash("vendor/" + modPath + "/" + file), true
+}
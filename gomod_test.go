@@ -0,0 +1,188 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/mod/module"
+)
+
+// resetGoModules clears the registered Go modules for the duration of a test.
+func resetGoModules(t *testing.T) {
+	t.Helper()
+	modules, requires := goModules, goRequires
+	goModules, goRequires = make(map[string]string), make(map[string]module.Version)
+	t.Cleanup(func() { goModules, goRequires = modules, requires })
+}
+
+func TestLoadGoModules(t *testing.T) {
+	resetGoModules(t)
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"go.work":                "go 1.21\n\nuse (\n\t./app\n\t./lib\n)\n",
+		"app/go.mod":             "module example.com/app\n\ngo 1.21\n\nreplace example.com/ext => ../third_party/ext\n",
+		"lib/go.mod":             "module example.com/lib\n\ngo 1.21\n",
+		"lib/sub/go.mod":         "module example.com/lib/sub\n\ngo 1.21\n",
+		"tools/go.mod":           "module example.com/tools\n\ngo 1.21\n",
+		"third_party/ext/go.mod": "module example.com/ext\n\ngo 1.21\n",
+		"testdata/go.mod":        "module example.com/testdata\n\ngo 1.21\n",
+		".hidden/go.mod":         "module example.com/hidden\n\ngo 1.21\n",
+	})
+	if err := loadGoModules(dir); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		importPath string
+		want       string
+	}{
+		{"example.com/app", "app"},
+		{"example.com/app/internal/x", "app/internal/x"},
+		{"example.com/lib/pkg", "lib/pkg"},
+		{"example.com/lib/sub/pkg", "lib/sub/pkg"},
+		{"example.com/tools/cmd", "tools/cmd"},
+		{"example.com/ext/y", "third_party/ext/y"},
+		{"example.com/testdata", ""},
+		{"example.com/hidden", ""},
+		{"example.com/libx", ""},
+		{"fmt", ""},
+	}
+	for _, tt := range tests {
+		got, ok := resolveGoImport(tt.importPath)
+		want := ""
+		if tt.want != "" {
+			want = filepath.Join(dir, filepath.FromSlash(tt.want))
+		}
+		if !ok {
+			got = ""
+		}
+		if got != want {
+			t.Errorf("resolveGoImport(%q) = %q, want %q", tt.importPath, got, want)
+		}
+	}
+}
+
+func TestLoadGoModulesInvalidGoWork(t *testing.T) {
+	resetGoModules(t)
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"go.work": "use (\n"})
+	if err := loadGoModules(dir); err == nil {
+		t.Error("loadGoModules accepted an invalid go.work")
+	}
+}
+
+func TestSetGoBase(t *testing.T) {
+	resetGoModules(t)
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"go.mod":     "module example.com/app\n\ngo 1.21\n",
+		"lib/go.mod": "module example.com/lib\n\ngo 1.21\n",
+	})
+	if err := loadGoModules(dir); err != nil {
+		t.Fatal(err)
+	}
+	setGoBase("example.com/base", dir)
+	for importPath, want := range map[string]bool{
+		"example.com/base/pkg": true,
+		"example.com/app/pkg":  false,
+		"example.com/lib/pkg":  true,
+	} {
+		if _, ok := resolveGoImport(importPath); ok != want {
+			t.Errorf("resolveGoImport(%q) followed = %v, want %v", importPath, ok, want)
+		}
+	}
+}
+
+func TestGoRequiresAndReplaces(t *testing.T) {
+	resetGoModules(t)
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"go.mod": `module example.com/app
+
+go 1.21
+
+require (
+	example.com/a v1.2.3
+	example.com/b v0.1.0 // indirect
+	example.com/local v0.0.0
+)
+
+replace example.com/b => example.com/fork/b v0.2.0
+
+replace example.com/local => ./local
+
+replace example.com/missing => ./missing
+`,
+		"local/go.mod": "module example.com/local\n",
+	})
+	if err := loadGoModules(dir); err != nil {
+		t.Fatal(err)
+	}
+	if got := goModules["example.com/local"]; got != filepath.Join(dir, "local") {
+		t.Errorf("example.com/local resolves to %q, want the local replacement", got)
+	}
+	if _, ok := goModules["example.com/missing"]; ok {
+		t.Error("a replacement without go.mod was registered")
+	}
+	want := map[string]module.Version{
+		"example.com/a":     {Path: "example.com/a", Version: "v1.2.3"},
+		"example.com/b":     {Path: "example.com/fork/b", Version: "v0.2.0"},
+		"example.com/local": {Path: "example.com/local", Version: "v0.0.0"},
+	}
+	for path, v := range want {
+		if got := goRequires[path]; got != v {
+			t.Errorf("goRequires[%q] = %v, want %v", path, got, v)
+		}
+	}
+}
+
+func TestResolveExternalImport(t *testing.T) {
+	resetGoModules(t)
+	defer func(patterns []string, depth int) {
+		externalPatterns, maxExternalDepth = patterns, depth
+	}(externalPatterns, maxExternalDepth)
+	dir := t.TempDir()
+	cache := filepath.Join(dir, "cache")
+	t.Setenv("GOMODCACHE", cache)
+	writeTree(t, dir, map[string]string{
+		"app/go.mod": "module example.com/app\n\ngo 1.21\n\nrequire (\n\tgithub.com/Upper/lib v1.0.0\n\texample.com/vendored v1.0.0\n)\n",
+		"app/vendor/example.com/vendored/pkg/v.go":       "package pkg\n",
+		"cache/github.com/!upper/lib@v1.0.0/go.mod":      "module github.com/Upper/lib\n",
+		"cache/github.com/!upper/lib@v1.0.0/sub/s.go":    "package sub\n",
+		"cache/github.com/!upper/lib@v1.0.0/nested/n.go": "package nested\n",
+	})
+	if err := loadGoModules(filepath.Join(dir, "app")); err != nil {
+		t.Fatal(err)
+	}
+	externalPatterns = []string{"github.com/Upper/*", "example.com/vendored"}
+	maxExternalDepth = 1
+	tests := []struct {
+		importPath string
+		want       string
+	}{
+		{"github.com/Upper/lib/sub", "cache/github.com/!upper/lib@v1.0.0/sub"},
+		{"example.com/vendored/pkg", "app/vendor/example.com/vendored/pkg"},
+		{"github.com/Upper/lib/missing", ""},
+		{"github.com/other/lib", ""},
+		{"fmt", ""},
+	}
+	for _, tt := range tests {
+		got, ok := followGoImport(tt.importPath, 0)
+		want := ""
+		if tt.want != "" {
+			want = filepath.Join(dir, filepath.FromSlash(tt.want))
+		}
+		if !ok {
+			got = ""
+		}
+		if got != want {
+			t.Errorf("followGoImport(%q, 0) = %q, want %q", tt.importPath, got, want)
+		}
+	}
+	if _, ok := followGoImport("github.com/Upper/lib/sub", 1); ok {
+		t.Error("followGoImport went beyond -max-depth")
+	}
+	file := filepath.Join(cache, "github.com", "!upper", "lib@v1.0.0", "sub", "s.go")
+	if got, ok := moduleCachePath(file); !ok || got != "vendor/github.com/Upper/lib/sub/s.go" {
+		t.Errorf("moduleCachePath(%q) = %q, %v", file, got, ok)
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// gocatIgnoreFile is the project-level ignore file read from the project root
+// in addition to .gitignore files. It uses the .gitignore syntax.
+const gocatIgnoreFile = ".gocatignore"
+
+// ignoreRule is a single pattern line from a .gitignore or .gocatignore file.
+type ignoreRule struct {
+	// base is the slash-separated directory of the ignore file, relative to
+	// the matcher root ("" for the root itself).
+	base     string
+	pattern  string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// ignoreMatcher decides whether paths below root are ignored by .gitignore
+// files (including nested ones) and the .gocatignore file of the project.
+// root is the top of the git work tree containing the project, so that
+// .gitignore files above the project root apply as well. Ignore files are
+// read lazily and cached per directory.
+type ignoreMatcher struct {
+	root string
+	// project is the slash-separated project root relative to root ("" if
+	// they are the same), where .gocatignore is read.
+	project string
+	rules   map[string][]ignoreRule
+}
+
+// ignore is the matcher used by join. It is nil when -no-ignore is set.
+var ignore *ignoreMatcher
+
+// newIgnoreMatcher returns a matcher for the project rooted at root. If root
+// is inside a git work tree, the rules of the .gitignore files from the top
+// of the work tree down apply.
+func newIgnoreMatcher(root string) (*ignoreMatcher, error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	m := &ignoreMatcher{root: absRoot, rules: make(map[string][]ignoreRule)}
+	if top, ok := gitWorkTree(absRoot); ok {
+		if rel, err := filepath.Rel(top, absRoot); err == nil && rel != "." {
+			m.root = top
+			m.project = filepath.ToSlash(rel)
+		}
+	}
+	return m, nil
+}
+
+// gitWorkTree returns the closest directory at or above dir that contains a
+// .git directory or file.
+func gitWorkTree(dir string) (string, bool) {
+	for {
+		if _, err := os.Lstat(filepath.Join(dir, ".git")); err == nil {
+			return dir, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// isIgnored reports whether filePath is ignored by the active matcher.
+func isIgnored(filePath string, isDir bool) bool {
+	if ignore == nil {
+		return false
+	}
+	return ignore.match(filePath, isDir)
+}
+
+// match reports whether filePath is ignored. As in git, a path is ignored if
+// any of its parent directories is ignored; otherwise the last matching rule
+// decides, with rules from deeper directories taking precedence.
+func (m *ignoreMatcher) match(filePath string, isDir bool) bool {
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(m.root, absPath)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return false
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	for i := range parts {
+		last := i == len(parts)-1
+		if m.matchRules(strings.Join(parts[:i+1], "/"), isDir || !last) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchRules applies the rules of every ignore file from the root down to
+// the parent directory of rel, without considering parent directories.
+func (m *ignoreMatcher) matchRules(rel string, isDir bool) bool {
+	ignored := false
+	dir := path.Dir(rel)
+	dirs := []string{""}
+	if dir != "." {
+		parts := strings.Split(dir, "/")
+		for i := range parts {
+			dirs = append(dirs, strings.Join(parts[:i+1], "/"))
+		}
+	}
+	for _, d := range dirs {
+		for _, rule := range m.load(d) {
+			if rule.dirOnly && !isDir {
+				continue
+			}
+			if rule.matches(rel) {
+				ignored = !rule.negate
+			}
+		}
+	}
+	return ignored
+}
+
+// load returns the rules of the ignore files in dir, reading them on first use.
+func (m *ignoreMatcher) load(dir string) []ignoreRule {
+	if rules, ok := m.rules[dir]; ok {
+		return rules
+	}
+	absDir := filepath.Join(m.root, filepath.FromSlash(dir))
+	var rules []ignoreRule
+	if dir == "" {
+		rules = parseIgnoreFile(filepath.Join(absDir, ".git", "info", "exclude"), dir)
+	}
+	rules = append(rules, parseIgnoreFile(filepath.Join(absDir, ".gitignore"), dir)...)
+	if dir == m.project {
+		rules = append(rules, parseIgnoreFile(filepath.Join(absDir, gocatIgnoreFile), dir)...)
+	}
+	m.rules[dir] = rules
+	return rules
+}
+
+// parseIgnoreFile reads the rules from an ignore file located in base. A
+// missing file yields no rules.
+func parseIgnoreFile(filePath, base string) []ignoreRule {
+	data, err := os.ReadFile(filepath.Clean(filePath))
+	if err != nil {
+		return nil
+	}
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if rule, ok := parseIgnoreLine(scanner.Text(), base); ok {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// parseIgnoreLine parses one line of .gitignore syntax.
+func parseIgnoreLine(line, base string) (ignoreRule, bool) {
+	line = strings.TrimSuffix(line, "\r")
+	if !strings.HasSuffix(line, "\\ ") {
+		line = strings.TrimRight(line, " \t")
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+	rule := ignoreRule{base: base}
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, "\\!") || strings.HasPrefix(line, "\\#") {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+	if strings.Contains(line, "/") {
+		rule.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+	rule.pattern = line
+	return rule, true
+}
+
+// matches reports whether the slash-separated path rel (relative to the
+// matcher root) matches the rule.
+func (r ignoreRule) matches(rel string) bool {
+	if r.base != "" {
+		if !strings.HasPrefix(rel, r.base+"/") {
+			return false
+		}
+		rel = strings.TrimPrefix(rel, r.base+"/")
+	}
+	if r.anchored {
+		return matchPattern(r.pattern, rel)
+	}
+	return matchPattern(r.pattern, path.Base(rel))
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseIgnoreLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want ignoreRule
+		ok   bool
+	}{
+		{"", ignoreRule{}, false},
+		{"# comment", ignoreRule{}, false},
+		{"   ", ignoreRule{}, false},
+		{"/", ignoreRule{}, false},
+		{"*.log", ignoreRule{pattern: "*.log"}, true},
+		{"*.log  \r", ignoreRule{pattern: "*.log"}, true},
+		{"trailing\\ ", ignoreRule{pattern: "trailing\\ "}, true},
+		{"!keep.log", ignoreRule{pattern: "keep.log", negate: true}, true},
+		{"\\!bang", ignoreRule{pattern: "!bang"}, true},
+		{"\\#hash", ignoreRule{pattern: "#hash"}, true},
+		{"build/", ignoreRule{pattern: "build", dirOnly: true}, true},
+		{"/root.txt", ignoreRule{pattern: "root.txt", anchored: true}, true},
+		{"docs/*.md", ignoreRule{pattern: "docs/*.md", anchored: true}, true},
+		{"!/out/", ignoreRule{pattern: "out", negate: true, dirOnly: true, anchored: true}, true},
+	}
+	for _, tt := range tests {
+		got, ok := parseIgnoreLine(tt.line, "")
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("parseIgnoreLine(%q) = %+v, %v, want %+v, %v", tt.line, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// writeTree creates files with the given contents below dir.
+func writeTree(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestIgnoreMatcher(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		".git/info/exclude":     "*.local\n",
+		".gitignore":            "*.secret\nbuild/\n/top.txt\n",
+		"svc/go.mod":            "module svc\n",
+		"svc/.gitignore":        "*.tmp\n!keep.secret\n",
+		"svc/.gocatignore":      "docs/\n",
+		"svc/pkg/.gitignore":    "!*.tmp\n",
+		"other/.gocatignore":    "*.go\n",
+		"svc/db.secret":         "",
+		"svc/keep.secret":       "",
+		"svc/a.tmp":             "",
+		"svc/pkg/b.tmp":         "",
+		"svc/build/out.go":      "",
+		"svc/docs/a.md":         "",
+		"svc/main.go":           "",
+		"svc/x.local":           "",
+		"svc/top.txt":           "",
+		"other/main.go":         "",
+		"svc/nested/top.secret": "",
+	})
+	m, err := newIgnoreMatcher(filepath.Join(dir, "svc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"svc/db.secret", true},
+		{"svc/nested/top.secret", true},
+		{"svc/keep.secret", false},
+		{"svc/a.tmp", true},
+		{"svc/pkg/b.tmp", false},
+		{"svc/build/out.go", true},
+		{"svc/docs/a.md", true},
+		{"svc/main.go", false},
+		{"svc/x.local", true},
+		{"svc/top.txt", false},
+		{"other/main.go", false},
+	}
+	for _, tt := range tests {
+		if got := m.match(filepath.Join(dir, filepath.FromSlash(tt.path)), false); got != tt.want {
+			t.Errorf("match(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestExpandArgumentsReportsIgnoredFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		".git/HEAD":  "",
+		".gitignore": "*.secret\n",
+		"a.secret":   "",
+		"b.secret":   "",
+		"main.go":    "",
+	})
+	m, err := newIgnoreMatcher(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(m *ignoreMatcher) { ignore = m }(ignore)
+	ignore = m
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	defer log.SetOutput(os.Stderr)
+	expandArguments([]string{filepath.Join(dir, "a.secret"), filepath.Join(dir, "*.secret"), filepath.Join(dir, "main.go")})
+	if got := strings.Count(logged.String(), "use -no-ignore"); got != 1 {
+		t.Errorf("got %d -no-ignore hints, want 1 for a.secret:\n%s", got, logged.String())
+	}
+	if !strings.Contains(logged.String(), "a.secret") {
+		t.Errorf("hint does not name a.secret:\n%s", logged.String())
+	}
+}
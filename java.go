@@ -0,0 +1,343 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// javaSourceRoots holds the absolute source root directories (such as
+// src/main/java) that Java and Kotlin imports are resolved against. They are
+// set via -java-src or detected by detectJavaSourceRoots.
+var javaSourceRoots []string
+
+// jvmSourceExts are the extensions of the files a JVM class may be declared in,
+// in lookup order.
+var jvmSourceExts = []string{".java", ".kt"}
+
+func init() {
+	registerResolver(javaResolver{})
+}
+
+// javaImportRegex matches Java import statements, including static and
+// wildcard imports.
+var javaImportRegex = regexp.MustCompile(`^\s*import\s+(static\s+)?([a-zA-Z0-9_$.]+?)(\.\*)?\s*;`)
+
+// javaResolver follows the imports of Java files to the class files in the
+// source roots, and includes the same-package classes a file refers to.
+type javaResolver struct{}
+
+// Extensions implements Resolver.
+func (javaResolver) Extensions() []string {
+	return []string{".java"}
+}
+
+// Dependencies implements Resolver.
+func (javaResolver) Dependencies(filePath string, data []byte) ([]string, error) {
+	var files []string
+	for _, line := range strings.Split(string(data), "\n") {
+		matches := javaImportRegex.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+		files = append(files, resolveJavaImport(matches[2], matches[1] != "", matches[3] != "")...)
+	}
+	return appendUnique(files, resolveSamePackage(filePath, data)...), nil
+}
+
+// detectJavaSourceRoots returns the Maven/Gradle source roots below root:
+// every src/<sourceSet>/java and src/<sourceSet>/kotlin directory, such as
+// src/main/java or src/test/kotlin, including those of submodules, followed by
+// the existing source directories configured in the modules' build files.
+func detectJavaSourceRoots(root string) []string {
+	roots := conventionalSourceRoots(root)
+	for _, module := range javaModules {
+		for _, dir := range module.sourceDirs {
+			if info, err := os.Stat(dir); err == nil && info.IsDir() {
+				roots = appendUnique(roots, dir)
+			}
+		}
+	}
+	return roots
+}
+
+// conventionalSourceRoots returns the src/*/java and src/*/kotlin directories
+// below root.
+func conventionalSourceRoots(root string) []string {
+	var roots []string
+	_ = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		if p != root && skipJavaDir(p, d.Name()) {
+			return filepath.SkipDir
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return nil
+		}
+		if isJavaSourceRoot(filepath.ToSlash(rel)) {
+			roots = append(roots, p)
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	return roots
+}
+
+// isJavaSourceRoot reports whether the slash-separated path rel ends in a
+// conventional source root such as src/main/java.
+func isJavaSourceRoot(rel string) bool {
+	parts := strings.Split(rel, "/")
+	if len(parts) < 3 {
+		return false
+	}
+	tail := strings.Join(parts[len(parts)-3:], "/")
+	for _, pattern := range []string{"src/*/java", "src/*/kotlin"} {
+		if match, _ := path.Match(pattern, tail); match {
+			return true
+		}
+	}
+	return false
+}
+
+// skipJavaDir reports whether a directory is skipped when looking for source
+// roots: build output, hidden directories and ignored directories.
+func skipJavaDir(p, name string) bool {
+	switch name {
+	case "build", "target", "out", "node_modules":
+		return true
+	}
+	return strings.HasPrefix(name, ".") || isIgnored(p, true)
+}
+
+// parseJavaSourceRoots turns the comma-separated -java-src value into
+// absolute directories, resolving relative paths against the project root.
+func parseJavaSourceRoots(value string) []string {
+	var roots []string
+	for _, dir := range strings.Split(value, ",") {
+		dir = strings.TrimSpace(dir)
+		if dir == "" {
+			continue
+		}
+		dir = filepath.Clean(dir)
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(rootDir, dir)
+		}
+		roots = append(roots, dir)
+	}
+	return roots
+}
+
+// resolveJavaImport returns the source files an import statement refers to.
+// Wildcard imports (import com.example.model.*) resolve to every class file
+// of the package. Static imports (import static com.example.Util.helper)
+// resolve to the file of the declaring class, and imports of nested classes
+// (import com.example.model.Order.Status) to the file of the outermost class.
+func resolveJavaImport(name string, static, wildcard bool) []string {
+	if wildcard && !static {
+		return resolveJavaPackage(name)
+	}
+	if static && !wildcard {
+		// Drop the imported member to get the declaring class.
+		i := strings.LastIndex(name, ".")
+		if i == -1 {
+			return nil
+		}
+		name = name[:i]
+	}
+	for {
+		if file, ok := resolveJavaClass(name); ok {
+			return []string{file}
+		}
+		// Retry with the enclosing class while the remaining name still ends
+		// in a class name (by convention, capitalized).
+		i := strings.LastIndex(name, ".")
+		if i == -1 || !isClassName(name[strings.LastIndex(name[:i], ".")+1:i]) {
+			return nil
+		}
+		name = name[:i]
+	}
+}
+
+// isClassName reports whether a name segment follows the Java convention for
+// class names of starting with an upper case letter.
+func isClassName(segment string) bool {
+	r, _ := utf8.DecodeRuneInString(segment)
+	return unicode.IsUpper(r)
+}
+
+// resolveJavaPackage returns the class files of a package in every source
+// root (or, without source roots, below the directories of the modules whose
+// base package contains it).
+func resolveJavaPackage(pkg string) []string {
+	var dirs []string
+	if len(javaSourceRoots) > 0 {
+		pkgPath := filepath.FromSlash(strings.ReplaceAll(pkg, ".", "/"))
+		for _, root := range javaSourceRoots {
+			dirs = append(dirs, filepath.Join(root, pkgPath))
+		}
+	} else {
+		for _, module := range javaModules {
+			if module.group == "" || (pkg != module.group && !strings.HasPrefix(pkg, module.group+".")) {
+				continue
+			}
+			relDir := strings.ReplaceAll(strings.TrimPrefix(strings.TrimPrefix(pkg, module.group), "."), ".", "/")
+			dirs = append(dirs, filepath.Join(module.dir, filepath.FromSlash(relDir)))
+		}
+	}
+	var files []string
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || !isJVMSourceFile(entry.Name()) {
+				continue
+			}
+			files = append(files, filepath.Join(dir, entry.Name()))
+		}
+	}
+	return files
+}
+
+// isJVMSourceFile reports whether name has one of jvmSourceExts.
+func isJVMSourceFile(name string) bool {
+	ext := filepath.Ext(name)
+	for _, e := range jvmSourceExts {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveJavaClass returns the source file declaring the fully qualified
+// class name. With source roots, the class is looked up as
+// <root>/<package path>/<Class>.java (or .kt) in every source root. Without
+// source roots, the base package of each matching module is stripped and the
+// remainder is looked up relative to the module directory.
+func resolveJavaClass(className string) (string, bool) {
+	var candidates []string
+	if len(javaSourceRoots) > 0 {
+		classPath := filepath.FromSlash(strings.ReplaceAll(className, ".", "/"))
+		for _, root := range javaSourceRoots {
+			candidates = append(candidates, filepath.Join(root, classPath))
+		}
+	} else {
+		for _, module := range javaModules {
+			if module.group == "" || !strings.HasPrefix(className, module.group+".") {
+				continue
+			}
+			classPath := strings.ReplaceAll(strings.TrimPrefix(className, module.group+"."), ".", "/")
+			candidates = append(candidates, filepath.Join(module.dir, filepath.FromSlash(classPath)))
+		}
+	}
+	for _, candidate := range candidates {
+		for _, ext := range jvmSourceExts {
+			if info, err := os.Stat(candidate + ext); err == nil && !info.IsDir() {
+				return candidate + ext, true
+			}
+		}
+	}
+	return "", false
+}
+
+var (
+	jvmPackageRegex    = regexp.MustCompile(`(?m)^\s*package\s+([a-zA-Z0-9_.]+)`)
+	jvmIdentifierRegex = regexp.MustCompile(`[A-Za-z_$][A-Za-z0-9_$]*`)
+	jvmCommentRegex    = regexp.MustCompile(`(?s)/\*.*?\*/|//[^\n]*|"(?:\\.|[^"\\\n])*"`)
+)
+
+// resolveSamePackage returns the class files of the same package as filePath
+// that the file refers to by simple name. Classes of the same package need no
+// import, so they are found by matching the names the file refers to (see
+// jvmReferences) against the class file names in the file's directory and
+// the package directory in every source root.
+func resolveSamePackage(filePath string, data []byte) []string {
+	dirs := []string{filepath.Dir(filePath)}
+	if m := jvmPackageRegex.FindSubmatch(data); m != nil {
+		pkgPath := filepath.FromSlash(strings.ReplaceAll(string(m[1]), ".", "/"))
+		for _, root := range javaSourceRoots {
+			dirs = append(dirs, filepath.Join(root, pkgPath))
+		}
+	}
+	identifiers := make(map[string]bool)
+	for _, name := range jvmReferences(data) {
+		identifiers[name] = true
+	}
+	self, _ := filepath.Abs(filePath)
+	seen := make(map[string]bool)
+	var files []string
+	for _, dir := range dirs {
+		absDir, err := filepath.Abs(dir)
+		if err != nil || seen[absDir] {
+			continue
+		}
+		seen[absDir] = true
+		entries, err := os.ReadDir(absDir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if entry.IsDir() || !isJVMSourceFile(name) {
+				continue
+			}
+			candidate := filepath.Join(absDir, name)
+			if candidate == self || !identifiers[strings.TrimSuffix(name, filepath.Ext(name))] {
+				continue
+			}
+			files = append(files, candidate)
+		}
+	}
+	return files
+}
+
+// jvmReferences returns the simple names a Java or Kotlin file refers to, in
+// order of first use: the identifiers outside comments, string literals and
+// package and import declarations, less the names bound by single-type
+// imports, which shadow classes of the same package.
+func jvmReferences(data []byte) []string {
+	var code, imported []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if m := javaImportRegex.FindStringSubmatch(line); m != nil {
+			if m[3] == "" {
+				imported = append(imported, m[2][strings.LastIndex(m[2], ".")+1:])
+			}
+			continue
+		}
+		if m := kotlinImportRegex.FindStringSubmatch(line); m != nil {
+			if m[2] == "" {
+				name := m[1]
+				if m[3] != "" {
+					name = m[3]
+				}
+				imported = append(imported, strings.ReplaceAll(name[strings.LastIndex(name, ".")+1:], "`", ""))
+			}
+			continue
+		}
+		if !jvmPackageRegex.MatchString(line) {
+			code = append(code, line)
+		}
+	}
+	seen := make(map[string]bool)
+	for _, name := range imported {
+		seen[name] = true
+	}
+	var names []string
+	for _, id := range jvmIdentifierRegex.FindAll(jvmCommentRegex.ReplaceAll([]byte(strings.Join(code, "\n")), nil), -1) {
+		if !seen[string(id)] {
+			seen[string(id)] = true
+			names = append(names, string(id))
+		}
+	}
+	return names
+}
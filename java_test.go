@@ -0,0 +1,118 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// setJavaProject writes files below a temporary directory, makes it the
+// project root and detects its Java source roots and modules.
+func setJavaProject(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	writeTree(t, dir, files)
+	root, roots, modules, kotlin := rootDir, javaSourceRoots, javaModules, kotlinFiles
+	t.Cleanup(func() { rootDir, javaSourceRoots, javaModules, kotlinFiles = root, roots, modules, kotlin })
+	rootDir, kotlinFiles = dir, nil
+	javaModules, _ = loadJavaModules(dir)
+	javaSourceRoots = detectJavaSourceRoots(dir)
+	return dir
+}
+
+// javaDeps returns the dependencies of a file below dir, relative to dir and
+// sorted.
+func javaDeps(t *testing.T, r Resolver, dir, name string, data string) []string {
+	t.Helper()
+	deps, err := r.Dependencies(filepath.Join(dir, filepath.FromSlash(name)), []byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rel []string
+	for _, dep := range deps {
+		p, err := filepath.Rel(dir, dep)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rel = append(rel, filepath.ToSlash(p))
+	}
+	sort.Strings(rel)
+	return rel
+}
+
+func TestJavaImports(t *testing.T) {
+	src := "src/main/java/com/example/"
+	dir := setJavaProject(t, map[string]string{
+		"pom.xml":                          "<project><groupId>com.example</groupId></project>",
+		src + "App.java":                   "",
+		src + "model/User.java":            "",
+		src + "util/Strings.kt":            "",
+		"src/test/java/com/example/T.java": "",
+	})
+	data := "package com.example;\n\nimport com.example.model.User;\nimport com.example.util.Strings;\n" +
+		"import com.example.T;\nimport java.util.List;\n"
+	got := javaDeps(t, javaResolver{}, dir, src+"App.java", data)
+	want := []string{src + "model/User.java", src + "util/Strings.kt", "src/test/java/com/example/T.java"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("dependencies = %v, want %v", got, want)
+	}
+}
+
+func TestJavaImportsWithoutSourceRoots(t *testing.T) {
+	dir := setJavaProject(t, map[string]string{
+		"App.java":        "",
+		"model/User.java": "",
+	})
+	javaModules = []javaModule{{dir: dir, group: "com.example"}}
+	got := javaDeps(t, javaResolver{}, dir, "App.java", "import com.example.model.User;\nimport org.other.X;\n")
+	if want := []string{"model/User.java"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("dependencies = %v, want %v", got, want)
+	}
+}
+
+func TestJavaWildcardStaticAndNestedImports(t *testing.T) {
+	src := "src/main/java/com/example/"
+	dir := setJavaProject(t, map[string]string{
+		"build.gradle":              "group = 'com.example'\n",
+		src + "App.java":            "",
+		src + "model/Order.java":    "",
+		src + "model/Item.java":     "",
+		src + "model/sub/Deep.java": "",
+		src + "util/Util.java":      "",
+		src + "util/Nums.java":      "",
+	})
+	data := "import com.example.model.*;\nimport static com.example.util.Util.helper;\n" +
+		"import static com.example.util.Nums.*;\nimport com.example.model.Order.Status.Code;\n"
+	got := javaDeps(t, javaResolver{}, dir, src+"App.java", data)
+	want := []string{src + "model/Item.java", src + "model/Order.java", src + "model/Order.java",
+		src + "util/Nums.java", src + "util/Util.java"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("dependencies = %v, want %v", got, want)
+	}
+}
+
+func TestJavaSamePackageClasses(t *testing.T) {
+	src := "src/main/java/com/example/"
+	dir := setJavaProject(t, map[string]string{
+		"pom.xml":                                "<project><groupId>com.example</groupId></project>",
+		src + "App.java":                         "",
+		src + "Helper.java":                      "",
+		src + "Mentioned.java":                   "",
+		src + "Quoted.java":                      "",
+		src + "Unused.java":                      "",
+		src + "Companion.kt":                     "",
+		src + "Order.java":                       "",
+		"src/main/java/com/other/Order.java":     "",
+		"src/test/java/com/example/Fixture.java": "",
+	})
+	// The single-type import of com.other.Order shadows com.example.Order.
+	data := "package com.example;\n\nimport com.other.Order;\n\n// Mentioned only in a comment.\nclass App {\n" +
+		"  Helper h = new Helper(\"Quoted\");\n  Companion c;\n  Fixture f;\n  Order o;\n}\n"
+	got := javaDeps(t, javaResolver{}, dir, src+"App.java", data)
+	want := []string{src + "Companion.kt", src + "Helper.java", "src/main/java/com/other/Order.java",
+		"src/test/java/com/example/Fixture.java"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("dependencies = %v, want %v", got, want)
+	}
+}
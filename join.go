@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"log"
+	"path/filepath"
+	"strings"
+)
+
+// joinOptions holds the flags of the join command, which stats accepts as
+// well.
+type joinOptions struct {
+	excludePackages *string
+	excludeFiles    *string
+	javaBase        *string
+	include         *string
+	javaSrc         *string
+	goBase          *string
+	root            *string
+	external        *string
+	maxDepth        *int
+	binary          *string
+	goos            *string
+	goarch          *string
+	tags            *string
+	tests           *bool
+	changedSince    *string
+	diff            *bool
+	shake           *string
+	noIgnore        *bool
+}
+
+// addJoinFlags defines the join flags on fs.
+func addJoinFlags(fs *flag.FlagSet) *joinOptions {
+	o := &joinOptions{}
+	o.excludePackages = fs.String("exclude-packages", "", "Comma-separated package names to exclude (for Go files)")
+	o.excludeFiles = fs.String("exclude-files", "", "Comma-separated file patterns to exclude")
+	o.javaBase = fs.String("java-base", "", "Comma-separated base packages for Java/Kotlin recursive dependency resolution")
+	o.include = fs.String("I", "", "Comma-separated include directories for C/C++ headers and .proto imports, relative to the project root")
+	o.javaSrc = fs.String("java-src", "", "Comma-separated Java/Kotlin source roots relative to the project root (default: detect src/*/java and src/*/kotlin)")
+	o.goBase = fs.String("go-base", "", "Base module for Go recursive dependency resolution (overrides the root go.mod)")
+	o.root = fs.String("root", "", "Project root (default: nearest workspace or directory with go.mod, pom.xml or build.gradle)")
+	o.external = fs.String("external", "", "Comma-separated module patterns of external Go dependencies to follow through vendor/ or the module cache")
+	o.maxDepth = fs.Int("max-depth", 1, "Maximum number of import hops to follow into external Go packages (with -external)")
+	o.binary = fs.String("binary", "encode", "How to handle binary files: skip, encode (base64) or raw")
+	o.goos = fs.String("goos", "", "Target operating system for Go build constraints (default: current GOOS)")
+	o.goarch = fs.String("goarch", "", "Target architecture for Go build constraints (default: current GOARCH)")
+	o.tags = fs.String("tags", "", "Comma-separated build tags for Go build constraints")
+	o.tests = fs.Bool("tests", false, "Include _test.go files of followed Go packages")
+	fs.IntVar(&importersDepth, "importers", 0, "Also include the files importing the given files, up to N levels (reverse dependencies)")
+	fs.IntVar(&importersDepth, "reverse", 0, "Alias for -importers")
+	o.changedSince = fs.String("changed-since", "", "Also join the files added or modified since the merge base of this git ref and HEAD")
+	o.diff = fs.Bool("diff", false, "With -changed-since, prepend the unified diff as a DIFF section")
+	fs.IntVar(&maxTokens, "max-tokens", 0, "Approximate token budget for the output; dependencies are dropped farthest first (0: unlimited)")
+	fs.IntVar(&maxBytes, "max-bytes", 0, "Byte budget for the output; dependencies are dropped farthest first (0: unlimited)")
+	o.shake = fs.String("shake", shakeOff, "Tree shaking for followed Go packages: off, files (only files with reachable declarations) or decls (only reachable declarations)")
+	o.noIgnore = fs.Bool("no-ignore", false, "Do not skip files matched by .gitignore or .gocatignore")
+	return o
+}
+
+// runJoin applies the join options and returns the file sections (without
+// the magic header) for the given files, directories and glob patterns.
+func runJoin(o *joinOptions, args []string) []byte {
+	if *o.diff && *o.changedSince == "" {
+		log.Fatal("-diff requires -changed-since")
+	}
+	switch *o.binary {
+	case "skip", "encode", "raw":
+		binaryMode = *o.binary
+	default:
+		log.Fatalf("Invalid -binary value %q: expected skip, encode or raw", *o.binary)
+	}
+	switch *o.shake {
+	case shakeOff, shakeFile, shakeDecls:
+		shakeMode = *o.shake
+	default:
+		log.Fatalf("Invalid -shake value %q: expected off, files or decls", *o.shake)
+	}
+	// Process exclusion flags.
+	if *o.excludePackages != "" {
+		for _, pkg := range strings.Split(*o.excludePackages, ",") {
+			excludePackages = append(excludePackages, strings.TrimSpace(pkg))
+		}
+	}
+	if *o.excludeFiles != "" {
+		for _, file := range strings.Split(*o.excludeFiles, ",") {
+			excludeFiles = append(excludeFiles, strings.TrimSpace(file))
+		}
+	}
+	setBuildTarget(*o.goos, *o.goarch, *o.tags)
+	includeTests = *o.tests
+	// Determine the project root. A root that is only the current directory
+	// is not walked for modules or source roots.
+	if *o.root != "" {
+		abs, err := filepath.Abs(filepath.Clean(*o.root))
+		if err != nil {
+			log.Fatalf("Invalid -root %q: %v", *o.root, err)
+		}
+		rootDir = abs
+	} else {
+		var found bool
+		rootDir, found = discoverRoot(args)
+		rootFallback = !found
+	}
+	if !*o.noIgnore {
+		m, err := newIgnoreMatcher(rootDir)
+		if err != nil {
+			log.Fatalf("Error reading ignore files: %v", err)
+		}
+		ignore = m
+	}
+	// Set Java/Kotlin base packages and modules.
+	if *o.javaBase != "" {
+		for _, base := range strings.Split(*o.javaBase, ",") {
+			javaModules = append(javaModules, javaModule{dir: rootDir, group: strings.TrimSpace(base)})
+		}
+	} else if modules, err := loadJavaModules(rootDir); err == nil {
+		javaModules = modules
+	} else {
+		log.Printf("Warning: unable to auto-detect Java base package: %v", err)
+	}
+	includeDirs = parseIncludeDirs(*o.include)
+	// Set Java/Kotlin source roots.
+	if *o.javaSrc != "" {
+		javaSourceRoots = parseJavaSourceRoots(*o.javaSrc)
+	} else if !rootFallback {
+		javaSourceRoots = detectJavaSourceRoots(rootDir)
+	}
+	// Determine the Go modules from go.work and the go.mod files in and below
+	// the project root; -go-base replaces the module path of the root.
+	if rootFallback {
+		log.Printf("Warning: no project root found; using %s without looking for modules below it", rootDir)
+	} else if err := loadGoModules(rootDir); err != nil {
+		log.Fatalf("Error reading go.work: %v", err)
+	}
+	if *o.goBase != "" {
+		setGoBase(strings.TrimSpace(*o.goBase), rootDir)
+	}
+	if !rootFallback && len(goModules) == 0 {
+		log.Printf("Warning: no go.mod or go.work found; Go imports will not be followed")
+	}
+	if *o.external != "" {
+		for _, pattern := range strings.Split(*o.external, ",") {
+			externalPatterns = append(externalPatterns, strings.TrimSpace(pattern))
+		}
+		maxExternalDepth = *o.maxDepth
+	}
+
+	files := expandArguments(args)
+	var buf bytes.Buffer
+	if *o.changedSince != "" {
+		base, err := changedSinceBase(*o.changedSince)
+		if err != nil {
+			log.Fatalf("Error resolving -changed-since %q: %v", *o.changedSince, err)
+		}
+		changed, err := changedFiles(base)
+		if err != nil {
+			log.Fatalf("Error listing changed files: %v", err)
+		}
+		if len(changed) == 0 {
+			log.Printf("No files changed since %s", *o.changedSince)
+		}
+		files = append(files, changed...)
+		if *o.diff {
+			if err := writeDiffSection(&buf, *o.changedSince, base); err != nil {
+				log.Fatalf("Error writing diff: %v", err)
+			}
+		}
+	}
+	if shakeMode != shakeOff {
+		shakeGo(files)
+	}
+	processed := make(map[string]bool)
+	if maxTokens > 0 || maxBytes > 0 {
+		joinWithBudget(files, processed, &buf)
+	} else {
+		for _, file := range files {
+			if err := processFile(file, processed, &buf); err != nil {
+				log.Printf("Error processing %s: %v", file, err)
+			}
+		}
+		for _, level := range importerLevels(files) {
+			for _, importer := range level {
+				if _, err := includeFile(importer, processed, &buf); err != nil {
+					log.Printf("Error processing %s: %v", importer, err)
+				}
+			}
+		}
+	}
+	return buf.Bytes()
+}
+
+// expandArguments expands the file, directory and glob pattern arguments of
+// join. Files named explicitly that the ignore files match are reported, as
+// they would otherwise be left out without notice.
+func expandArguments(args []string) []string {
+	var files []string
+	for _, pattern := range args {
+		pattern = filepath.Clean(pattern)
+		matches, err := expandArgument(pattern)
+		if err != nil {
+			log.Printf("Invalid glob pattern %q: %v", pattern, err)
+			continue
+		}
+		if len(matches) == 0 {
+			log.Printf("No matches found for pattern %q", pattern)
+			continue
+		}
+		for _, file := range matches {
+			file = filepath.Clean(file)
+			if file == pattern && isIgnored(file, false) {
+				log.Printf("Skipping %s: ignored by .gitignore or .gocatignore (use -no-ignore to include it)", file)
+			}
+			files = append(files, file)
+		}
+	}
+	return files
+}
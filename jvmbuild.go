@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// javaModule is a Maven module or Gradle (sub)project.
+type javaModule struct {
+	// dir is the absolute module directory.
+	dir string
+	// group is the module's groupId / group, used as its base package.
+	group string
+	// sourceDirs are source directories configured in the build file in
+	// addition to the src/*/java conventions.
+	sourceDirs []string
+}
+
+// javaModules holds the modules of the build found in the project root, or a
+// single module per package given via -java-base.
+var javaModules []javaModule
+
+// mavenPOM holds the parts of a pom.xml gocat uses. Only direct children of
+// <project> are matched, so the groupIds of dependencies and plugins are not
+// mistaken for the project's own.
+type mavenPOM struct {
+	GroupID string `xml:"groupId"`
+	Parent  struct {
+		GroupID string `xml:"groupId"`
+	} `xml:"parent"`
+	Modules  []string `xml:"modules>module"`
+	Profiles []struct {
+		Modules []string `xml:"modules>module"`
+	} `xml:"profiles>profile"`
+	Build struct {
+		SourceDirectory     string `xml:"sourceDirectory"`
+		TestSourceDirectory string `xml:"testSourceDirectory"`
+	} `xml:"build"`
+}
+
+var (
+	gradleIncludeRegex    = regexp.MustCompile(`(?m)^\s*include\b[ \t(]*(.*)$`)
+	gradleProjectDirRegex = regexp.MustCompile(`project\(\s*['"]([^'"]+)['"]\s*\)\.projectDir\s*=\s*(?:file\(|new\s+File\(\s*(?:rootDir|settingsDir)\s*,)\s*['"]([^'"]+)['"]`)
+	gradleGroupRegex      = regexp.MustCompile(`(?m)(?:^|[\s{;])(?:project\.)?group\s*=\s*['"]([^'"]+)['"]`)
+	gradleSrcDirRegex     = regexp.MustCompile(`srcDirs?\b[^\n]*`)
+	quotedStringRegex     = regexp.MustCompile(`['"]([^'"]+)['"]`)
+)
+
+// loadJavaModules detects the Maven or Gradle build in root, including the
+// modules of a multi-module Maven build (<modules>, also within profiles) and
+// the subprojects of a Gradle build (settings.gradle(.kts) include
+// statements). Groups are inherited from a parent POM or from the root
+// project (for example an allprojects { group = ... } block).
+func loadJavaModules(root string) ([]javaModule, error) {
+	if _, err := os.Stat(filepath.Join(root, "pom.xml")); err == nil {
+		modules := loadMavenModules(root, "", make(map[string]bool))
+		if len(modules) == 0 {
+			return nil, fmt.Errorf("groupId not found in pom.xml")
+		}
+		return modules, nil
+	}
+	for _, name := range []string{"settings.gradle", "settings.gradle.kts", "build.gradle", "build.gradle.kts"} {
+		if _, err := os.Stat(filepath.Join(root, name)); err == nil {
+			modules := loadGradleModules(root)
+			if len(modules) == 0 {
+				return nil, fmt.Errorf("group not found in Gradle build files")
+			}
+			return modules, nil
+		}
+	}
+	return nil, fmt.Errorf("no recognized Java build file found")
+}
+
+// loadMavenModules reads the pom.xml in dir and, recursively, those of its
+// modules. parentGroup is the groupId inherited from the aggregating POM.
+func loadMavenModules(dir, parentGroup string, seen map[string]bool) []javaModule {
+	if seen[dir] {
+		return nil
+	}
+	seen[dir] = true
+	data, err := os.ReadFile(filepath.Join(dir, "pom.xml"))
+	if err != nil {
+		return nil
+	}
+	var pom mavenPOM
+	if err := xml.Unmarshal(data, &pom); err != nil {
+		return nil
+	}
+	group := strings.TrimSpace(pom.GroupID)
+	if group == "" {
+		group = strings.TrimSpace(pom.Parent.GroupID)
+	}
+	if group == "" {
+		group = parentGroup
+	}
+	var modules []javaModule
+	if group != "" {
+		module := javaModule{dir: dir, group: group}
+		for _, src := range []string{pom.Build.SourceDirectory, pom.Build.TestSourceDirectory} {
+			if src = strings.TrimSpace(src); src != "" && !strings.Contains(src, "${") {
+				module.sourceDirs = append(module.sourceDirs, resolveModulePath(dir, src))
+			}
+		}
+		modules = append(modules, module)
+	}
+	names := pom.Modules
+	for _, profile := range pom.Profiles {
+		names = append(names, profile.Modules...)
+	}
+	for _, name := range names {
+		modules = append(modules, loadMavenModules(resolveModulePath(dir, strings.TrimSpace(name)), group, seen)...)
+	}
+	return modules
+}
+
+// loadGradleModules reads the root project and the subprojects included by
+// the settings file of the Gradle build in root.
+func loadGradleModules(root string) []javaModule {
+	rootGroup, rootSrc := readGradleBuild(root)
+	var modules []javaModule
+	if rootGroup != "" {
+		modules = append(modules, javaModule{dir: root, group: rootGroup, sourceDirs: rootSrc})
+	}
+	for _, dir := range gradleSubprojects(root) {
+		group, src := readGradleBuild(dir)
+		if group == "" {
+			group = rootGroup
+		}
+		if group != "" {
+			modules = append(modules, javaModule{dir: dir, group: group, sourceDirs: src})
+		}
+	}
+	return modules
+}
+
+// gradleSubprojects returns the directories of the subprojects included by
+// settings.gradle or settings.gradle.kts in root. Project paths such as
+// ":lib:core" map to lib/core unless a projectDir is assigned.
+func gradleSubprojects(root string) []string {
+	var data []byte
+	for _, name := range []string{"settings.gradle", "settings.gradle.kts"} {
+		if d, err := os.ReadFile(filepath.Join(root, name)); err == nil {
+			data = d
+			break
+		}
+	}
+	if data == nil {
+		return nil
+	}
+	projectDirs := make(map[string]string)
+	for _, m := range gradleProjectDirRegex.FindAllSubmatch(data, -1) {
+		projectDirs[string(m[1])] = string(m[2])
+	}
+	var dirs []string
+	for _, m := range gradleIncludeRegex.FindAllSubmatch(data, -1) {
+		for _, q := range quotedStringRegex.FindAllSubmatch(m[1], -1) {
+			projectPath := string(q[1])
+			relDir, ok := projectDirs[projectPath]
+			if !ok {
+				relDir = strings.ReplaceAll(strings.TrimPrefix(projectPath, ":"), ":", "/")
+			}
+			dirs = append(dirs, resolveModulePath(root, relDir))
+		}
+	}
+	return dirs
+}
+
+// readGradleBuild returns the group and the configured source directories of
+// the Gradle project in dir.
+func readGradleBuild(dir string) (string, []string) {
+	for _, name := range []string{"build.gradle", "build.gradle.kts"} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+		var group string
+		if m := gradleGroupRegex.FindSubmatch(data); m != nil {
+			group = string(m[1])
+		}
+		var sourceDirs []string
+		for _, line := range gradleSrcDirRegex.FindAll(data, -1) {
+			for _, q := range quotedStringRegex.FindAllSubmatch(line, -1) {
+				sourceDirs = append(sourceDirs, resolveModulePath(dir, string(q[1])))
+			}
+		}
+		return group, sourceDirs
+	}
+	return "", nil
+}
+
+// resolveModulePath resolves a path from a build file against its directory.
+func resolveModulePath(dir, p string) string {
+	p = filepath.FromSlash(p)
+	if filepath.IsAbs(p) {
+		return filepath.Clean(p)
+	}
+	return filepath.Join(dir, p)
+}
+
+// isMavenAggregator reports whether dir holds a pom.xml that lists modules.
+func isMavenAggregator(dir string) bool {
+	data, err := os.ReadFile(filepath.Join(dir, "pom.xml"))
+	if err != nil {
+		return false
+	}
+	var pom mavenPOM
+	return xml.Unmarshal(data, &pom) == nil && len(pom.Modules) > 0
+}
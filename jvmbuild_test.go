@@ -0,0 +1,88 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// moduleSummary returns "dir=group" for each module, relative to root and
+// sorted.
+func moduleSummary(t *testing.T, root string, modules []javaModule) []string {
+	t.Helper()
+	var summary []string
+	for _, m := range modules {
+		rel, err := filepath.Rel(root, m.dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		summary = append(summary, filepath.ToSlash(rel)+"="+m.group)
+	}
+	sort.Strings(summary)
+	return summary
+}
+
+// sourceDirsOf returns the configured source directories of the module in dir.
+func sourceDirsOf(modules []javaModule, dir string) []string {
+	for _, m := range modules {
+		if m.dir == dir {
+			return m.sourceDirs
+		}
+	}
+	return nil
+}
+
+func TestLoadMavenModules(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"pom.xml": `<project>
+  <groupId>com.acme</groupId>
+  <modules><module>core</module><module>api</module></modules>
+  <profiles><profile><modules><module>extra</module></modules></profile></profiles>
+  <dependencies><dependency><groupId>org.other</groupId></dependency></dependencies>
+</project>`,
+		"core/pom.xml":  `<project><parent><groupId>com.acme</groupId></parent><build><sourceDirectory>src/java</sourceDirectory></build></project>`,
+		"api/pom.xml":   `<project><groupId>com.acme.api</groupId></project>`,
+		"extra/pom.xml": `<project></project>`,
+	})
+	modules, err := loadJavaModules(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{".=com.acme", "api=com.acme.api", "core=com.acme", "extra=com.acme"}
+	if got := moduleSummary(t, dir, modules); !reflect.DeepEqual(got, want) {
+		t.Errorf("modules = %v, want %v", got, want)
+	}
+	srcDir := filepath.Join(dir, "core", "src", "java")
+	if got := sourceDirsOf(modules, filepath.Join(dir, "core")); !reflect.DeepEqual(got, []string{srcDir}) {
+		t.Errorf("source directories of core = %v, want %v", got, []string{srcDir})
+	}
+	if !isMavenAggregator(dir) || isMavenAggregator(filepath.Join(dir, "core")) {
+		t.Error("isMavenAggregator does not recognize the aggregating POM")
+	}
+}
+
+func TestLoadGradleModules(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"settings.gradle.kts": "rootProject.name = \"acme\"\ninclude(\":app\", \":libs:util\")\ninclude(\":renamed\")\n" +
+			"project(\":renamed\").projectDir = file(\"modules/renamed\")\n",
+		"build.gradle.kts":                 "allprojects {\n    group = \"com.acme\"\n}\n",
+		"app/build.gradle.kts":             "group = \"com.acme.app\"\n",
+		"libs/util/build.gradle":           "sourceSets { main { java { srcDirs = ['src/gen'] } } }\n",
+		"modules/renamed/build.gradle.kts": "",
+	})
+	modules, err := loadJavaModules(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{".=com.acme", "app=com.acme.app", "libs/util=com.acme", "modules/renamed=com.acme"}
+	if got := moduleSummary(t, dir, modules); !reflect.DeepEqual(got, want) {
+		t.Errorf("modules = %v, want %v", got, want)
+	}
+	srcDir := filepath.Join(dir, "libs", "util", "src", "gen")
+	if got := sourceDirsOf(modules, filepath.Join(dir, "libs", "util")); !reflect.DeepEqual(got, []string{srcDir}) {
+		t.Errorf("source directories of libs/util = %v, want %v", got, []string{srcDir})
+	}
+}
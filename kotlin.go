@@ -0,0 +1,190 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// kotlinIndex maps Kotlin packages and top-level declarations to the files
+// declaring them. Kotlin files need not live in a directory matching their
+// package, and a single file may declare several classes, functions and
+// properties, so imports are resolved through this index rather than paths.
+type kotlinIndex struct {
+	// packages maps a package name to the files declaring it.
+	packages map[string][]string
+	// declarations maps a fully qualified top-level name to its files.
+	declarations map[string][]string
+}
+
+var (
+	// kotlinFiles is built on first use by loadKotlinIndex.
+	kotlinFiles *kotlinIndex
+
+	kotlinImportRegex = regexp.MustCompile("^\\s*import\\s+([a-zA-Z0-9_.`]+?)(\\.\\*)?(?:\\s+as\\s+([a-zA-Z0-9_`]+))?\\s*;?\\s*$")
+	// kotlinDeclRegex matches top-level (unindented) declarations. Function
+	// and property names may be preceded by a receiver type for extensions.
+	kotlinDeclRegex = regexp.MustCompile(`(?m)^(?:(?:public|private|internal|protected|open|abstract|sealed|data|enum|annotation|inline|value|inner|suspend|operator|infix|tailrec|external|const|lateinit|expect|actual|fun)\s+)*(class|interface|object|typealias|fun|val|var)\s+(?:<[^>]*>\s*)?(?:[A-Za-z0-9_.<>?, ]*\.)?` + "`?([A-Za-z_][A-Za-z0-9_]*)`?")
+)
+
+func init() {
+	registerResolver(kotlinResolver{})
+}
+
+// kotlinResolver follows the imports of Kotlin files through the Kotlin
+// declaration index, falling back to Java class files, and includes the
+// same-package classes and declarations a file refers to.
+type kotlinResolver struct{}
+
+// Extensions implements Resolver.
+func (kotlinResolver) Extensions() []string {
+	return []string{".kt", ".kts"}
+}
+
+// Dependencies implements Resolver.
+func (kotlinResolver) Dependencies(filePath string, data []byte) ([]string, error) {
+	var files []string
+	for _, line := range strings.Split(string(data), "\n") {
+		files = appendUnique(files, resolveKotlinImport(strings.TrimSuffix(line, "\r"))...)
+	}
+	files = appendUnique(files, resolveSamePackage(filePath, data)...)
+	return appendUnique(files, loadKotlinIndex().samePackage(filePath, data)...), nil
+}
+
+// loadKotlinIndex returns the index of Kotlin files in the source roots, or
+// below the project root if there are none. A root that is only the current
+// directory is not walked.
+func loadKotlinIndex() *kotlinIndex {
+	if kotlinFiles != nil {
+		return kotlinFiles
+	}
+	kotlinFiles = &kotlinIndex{packages: make(map[string][]string), declarations: make(map[string][]string)}
+	roots := javaSourceRoots
+	if len(roots) == 0 && !rootFallback {
+		roots = []string{rootDir}
+	}
+	for _, root := range roots {
+		_ = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if d.IsDir() {
+				if p != root && skipJavaDir(p, d.Name()) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if ext := filepath.Ext(p); ext == ".kt" || ext == ".kts" {
+				kotlinFiles.add(p)
+			}
+			return nil
+		})
+	}
+	return kotlinFiles
+}
+
+// add indexes the package and top-level declarations of a Kotlin file.
+func (idx *kotlinIndex) add(filePath string) {
+	data, err := os.ReadFile(filepath.Clean(filePath))
+	if err != nil {
+		return
+	}
+	pkg := kotlinPackage(data)
+	idx.packages[pkg] = append(idx.packages[pkg], filePath)
+	for _, m := range kotlinDeclRegex.FindAllSubmatch(jvmCommentRegex.ReplaceAll(data, nil), -1) {
+		name := qualify(pkg, string(m[2]))
+		idx.declarations[name] = appendUnique(idx.declarations[name], filePath)
+	}
+}
+
+// resolve returns the files an import refers to: every file of the package
+// for wildcard imports, otherwise the files declaring the imported class,
+// object, function or property. Imports of members of classes and objects
+// resolve to the file declaring the outer declaration.
+func (idx *kotlinIndex) resolve(name string, wildcard bool) []string {
+	if wildcard {
+		return idx.packages[name]
+	}
+	for {
+		if files, ok := idx.declarations[name]; ok {
+			return files
+		}
+		i := strings.LastIndex(name, ".")
+		if i == -1 {
+			return nil
+		}
+		name = name[:i]
+		if _, ok := idx.packages[name]; ok {
+			return nil
+		}
+	}
+}
+
+// samePackage returns the other files of the package of a Kotlin file that
+// declare a top-level name the file refers to by its simple name.
+func (idx *kotlinIndex) samePackage(filePath string, data []byte) []string {
+	self, _ := filepath.Abs(filePath)
+	pkg := kotlinPackage(data)
+	var files []string
+	for _, id := range jvmReferences(data) {
+		for _, f := range idx.declarations[qualify(pkg, id)] {
+			if abs, _ := filepath.Abs(f); abs != self {
+				files = appendUnique(files, f)
+			}
+		}
+	}
+	return files
+}
+
+// resolveKotlinImport resolves an import line of a Kotlin file, including
+// aliased imports (import a.b.C as D), through the Kotlin index, falling back
+// to Java classes for imports of Java code.
+func resolveKotlinImport(line string) []string {
+	matches := kotlinImportRegex.FindStringSubmatch(line)
+	if matches == nil {
+		return nil
+	}
+	name := strings.ReplaceAll(matches[1], "`", "")
+	wildcard := matches[2] != ""
+	files := loadKotlinIndex().resolve(name, wildcard)
+	if len(files) > 0 {
+		return files
+	}
+	return resolveJavaImport(name, false, wildcard)
+}
+
+// kotlinPackage returns the package declared by a Kotlin file, or "" for the
+// default package.
+func kotlinPackage(data []byte) string {
+	if m := jvmPackageRegex.FindSubmatch(data); m != nil {
+		return strings.ReplaceAll(string(m[1]), "`", "")
+	}
+	return ""
+}
+
+// qualify joins a package name and a simple name.
+func qualify(pkg, name string) string {
+	if pkg == "" {
+		return name
+	}
+	return pkg + "." + name
+}
+
+// appendUnique appends the values to list that it does not already contain.
+func appendUnique(list []string, values ...string) []string {
+	for _, s := range values {
+		found := false
+		for _, v := range list {
+			if v == s {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, s)
+		}
+	}
+	return list
+}
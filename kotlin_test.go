@@ -0,0 +1,52 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestKotlinImports(t *testing.T) {
+	src := "src/main/kotlin/"
+	dir := setJavaProject(t, map[string]string{
+		"build.gradle.kts": "group = \"com.example\"\n",
+		src + "App.kt":     "",
+		// Files need not live in their package's directory.
+		src + "misc/Models.kt": "package com.example.model\n\ndata class User(val name: String)\n" +
+			"sealed class Shape {\n    object Circle : Shape()\n}\n",
+		src + "misc/Ext.kt":                         "package com.example.util\n\nfun String.shout() = uppercase()\nval VERSION = 1\n",
+		src + "misc/Aliased.kt":                     "package com.example.util\n\ntypealias Id = String\n",
+		src + "misc/Same.kt":                        "package com.example\n\ninternal fun helper() = 1\n",
+		src + "misc/Unused.kt":                      "package com.example\n\nclass Unused\n",
+		src + "wild/A.kt":                           "package com.example.wild\n\nclass A\n",
+		src + "wild/B.kt":                           "package com.example.wild\n\nclass B\n",
+		"src/main/java/com/example/legacy/Old.java": "",
+	})
+	data := "package com.example\n\nimport com.example.model.User\nimport com.example.model.Shape.Circle\n" +
+		"import com.example.util.shout\nimport com.example.util.Id as Key\nimport com.example.wild.*\n" +
+		"import com.example.legacy.Old\n\nfun main() { helper() }\n"
+	got := javaDeps(t, kotlinResolver{}, dir, src+"App.kt", data)
+	want := []string{"src/main/java/com/example/legacy/Old.java", src + "misc/Aliased.kt", src + "misc/Ext.kt",
+		src + "misc/Models.kt", src + "misc/Same.kt", src + "wild/A.kt", src + "wild/B.kt"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("dependencies = %v, want %v", got, want)
+	}
+}
+
+func TestKotlinIndexFallbackRoot(t *testing.T) {
+	dir := setJavaProject(t, map[string]string{"A.kt": "package a\n\nclass A\n"})
+	defer func(fallback bool) { rootFallback = fallback }(rootFallback)
+	for _, fallback := range []bool{false, true} {
+		rootFallback, kotlinFiles = fallback, nil
+		got := loadKotlinIndex().resolve("a.A", false)
+		if want := !fallback; (len(got) == 1) != want {
+			t.Errorf("rootFallback=%v: a.A resolves to %v in %s", fallback, got, dir)
+		}
+	}
+}
+
+func TestAppendUnique(t *testing.T) {
+	got := appendUnique([]string{"a"}, "b", "a", "c", "b")
+	if want := []string{"a", "b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("appendUnique = %v, want %v", got, want)
+	}
+}
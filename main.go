@@ -1,11 +1,10 @@
 package main
 
 import (
-	"bufio"
-	"bytes"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"go/build"
 	"go/parser"
 	"go/token"
 	"io"
@@ -13,9 +12,8 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
-	"regexp"
+	"runtime"
 	"runtime/debug"
-	"strconv"
 	"strings"
 	"time"
 
@@ -24,10 +22,13 @@ import (
 
 const (
 	magicHeader     = "// --------- gocat v1"
-	fileStartFormat = "// --------- FILE START: \"%s\" (size: %d bytes, modtime: %s) ----------\n"
+	magicHeaderV2   = "// --------- gocat v2"
+	fileStartFormat = "// --------- FILE START: \"%s\" (size: %d bytes, modtime: %s%s) ----------\n"
 	fileEndFormat   = "// --------- FILE END: \"%s\" ----------\n"
 	fileStartPrefix = "// --------- FILE START: "
-	fileEndPrefix   = "// --------- FILE END: "
+	diffStartFormat = "// --------- DIFF START: \"%s\" (size: %d bytes) ----------\n"
+	diffEnd         = "// --------- DIFF END ----------\n"
+	diffStartPrefix = "// --------- DIFF START: "
 )
 
 const (
@@ -48,9 +49,15 @@ var (
 	excludePackages []string
 	excludeFiles    []string
 
-	// Base package for Java/Kotlin recursive dependency resolution.
-	// Can be specified via -java-base or auto-detected from build files.
-	javaBase string
+	// How join handles binary files: "encode" (base64), "skip" or "raw".
+	binaryMode = "encode"
+
+	// Build context used to select the files of followed Go packages.
+	// GOOS, GOARCH and build tags can be overridden via -goos, -goarch and -tags.
+	buildContext = build.Default
+
+	// Whether _test.go files of followed Go packages are included (-tests).
+	includeTests bool
 )
 
 func main() {
@@ -76,97 +83,84 @@ func main() {
 	switch command {
 	case "join":
 		joinCmd := flag.NewFlagSet("join", flag.ExitOnError)
-		excludePkgs := joinCmd.String("exclude-packages", "", "Comma-separated package names to exclude (for Go files)")
-		excludeFilesFlag := joinCmd.String("exclude-files", "", "Comma-separated file patterns to exclude")
-		javaBaseFlag := joinCmd.String("java-base", "", "Base package for Java/Kotlin recursive dependency resolution")
-		goBaseFlag := joinCmd.String("go-base", "", "Base module for Go recursive dependency resolution (overrides go.mod)")
+		opts := addJoinFlags(joinCmd)
 		if err := joinCmd.Parse(os.Args[2:]); err != nil {
 			log.Fatalf("Error parsing join command: %v", err)
 		}
-		if joinCmd.NArg() == 0 {
-			log.Fatal("Usage: join [file or glob pattern] ...")
-		}
-		// Process exclusion flags.
-		if *excludePkgs != "" {
-			for _, pkg := range strings.Split(*excludePkgs, ",") {
-				excludePackages = append(excludePackages, strings.TrimSpace(pkg))
-			}
-		}
-		if *excludeFilesFlag != "" {
-			for _, file := range strings.Split(*excludeFilesFlag, ",") {
-				excludeFiles = append(excludeFiles, strings.TrimSpace(file))
-			}
-		}
-		// Set Java/Kotlin base package.
-		javaBase = strings.TrimSpace(*javaBaseFlag)
-		if javaBase == "" {
-			if jb, err := getJavaModuleName(); err == nil {
-				javaBase = jb
-			} else {
-				log.Printf("Warning: unable to auto-detect Java base package: %v", err)
-			}
-		}
-		// Determine Go module name from the local go.mod.
-		var moduleName string
-		if *goBaseFlag != "" {
-			moduleName = strings.TrimSpace(*goBaseFlag)
-		} else {
-			var err error
-			moduleName, err = getGoModuleName()
-			if err != nil {
-				log.Fatalf("Error reading go.mod: %v", err)
-			}
-		}
-
-		var buf bytes.Buffer
-		processed := make(map[string]bool)
-		for _, pattern := range joinCmd.Args() {
-			pattern = filepath.Clean(pattern)
-			matches, err := filepath.Glob(pattern)
-			if err != nil {
-				log.Printf("Invalid glob pattern %q: %v", pattern, err)
-				continue
-			}
-			if len(matches) == 0 {
-				log.Printf("No matches found for pattern %q", pattern)
-				continue
-			}
-			for _, file := range matches {
-				file = filepath.Clean(file)
-				if err := processFile(file, moduleName, processed, &buf); err != nil {
-					log.Printf("Error processing %s: %v", file, err)
-				}
-			}
+		if joinCmd.NArg() == 0 && *opts.changedSince == "" {
+			log.Fatal("Usage: join [file, directory or glob pattern] ...")
 		}
-		if buf.Len() > 0 {
-			fmt.Println(magicHeader)
-			fmt.Print(buf.String())
+		out := runJoin(opts, joinCmd.Args())
+		if len(out) > 0 {
+			fmt.Println(magicHeaderV2)
+			fmt.Print(string(out))
 		}
 	case "split":
 		splitCmd := flag.NewFlagSet("split", flag.ExitOnError)
 		inputFile := splitCmd.String("in", "", "Input file to split (default: STDIN)")
 		outputDir := splitCmd.String("out", "", "Output directory (default: current directory)")
+		strict := splitCmd.Bool("strict", false, "Fail without writing any file if a file does not match its header size or checksum")
+		noPreserve := splitCmd.Bool("no-preserve", false, "Do not restore file modes and modification times from the headers")
 		if err := splitCmd.Parse(os.Args[2:]); err != nil {
 			log.Fatalf("Error parsing split command: %v", err)
 		}
-		var in io.Reader
-		if *inputFile != "" {
-			*inputFile = filepath.Clean(*inputFile)
-			f, err := os.Open(*inputFile)
-			if err != nil {
-				log.Fatalf("Error opening input file %q: %v", *inputFile, err)
+		in, err := openInput(*inputFile)
+		if err != nil {
+			log.Fatalf("Error opening input file %q: %v", *inputFile, err)
+		}
+		defer func() {
+			if err := in.Close(); err != nil {
+				log.Printf("Error closing input file: %v", err)
 			}
-			defer func() {
-				if err := f.Close(); err != nil {
-					log.Printf("Error closing input file: %v", err)
-				}
-			}()
-			in = f
+		}()
+		if err := splitInput(in, *outputDir, *strict, !*noPreserve); err != nil {
+			log.Fatalf("Error splitting input: %v", err)
+		}
+	case "verify":
+		verifyCmd := flag.NewFlagSet("verify", flag.ExitOnError)
+		inputFile := verifyCmd.String("in", "", "Input file to verify (default: STDIN)")
+		if err := verifyCmd.Parse(os.Args[2:]); err != nil {
+			log.Fatalf("Error parsing verify command: %v", err)
+		}
+		in, err := openInput(*inputFile)
+		if err != nil {
+			log.Fatalf("Error opening input file %q: %v", *inputFile, err)
+		}
+		ok, err := verifyInput(in, os.Stdout)
+		if cerr := in.Close(); cerr != nil {
+			log.Printf("Error closing input file: %v", cerr)
+		}
+		if err != nil {
+			log.Fatalf("Error verifying input: %v", err)
+		}
+		if !ok {
+			os.Exit(1)
+		}
+	case "stats":
+		statsCmd := flag.NewFlagSet("stats", flag.ExitOnError)
+		opts := addJoinFlags(statsCmd)
+		inputFile := statsCmd.String("in", "", "Bundle to report on (default: STDIN, unless files to join are given)")
+		jsonOut := statsCmd.Bool("json", false, "Print the report as JSON")
+		if err := statsCmd.Parse(os.Args[2:]); err != nil {
+			log.Fatalf("Error parsing stats command: %v", err)
+		}
+		var in io.ReadCloser
+		if *inputFile == "" && (statsCmd.NArg() > 0 || *opts.changedSince != "") {
+			// Dry run of join: report on the bundle it would write.
+			out := runJoin(opts, statsCmd.Args())
+			in = io.NopCloser(strings.NewReader(magicHeaderV2 + "\n" + string(out)))
 		} else {
-			in = os.Stdin
+			var err error
+			if in, err = openInput(*inputFile); err != nil {
+				log.Fatalf("Error opening input file %q: %v", *inputFile, err)
+			}
 		}
-		if err := splitInput(in, *outputDir); err != nil {
-			log.Fatalf("Error splitting input: %v", err)
+		err := statsInput(in, os.Stdout, *jsonOut)
+		if cerr := in.Close(); cerr != nil {
+			log.Printf("Error closing input file: %v", cerr)
+		}
+		if err != nil {
+			log.Fatalf("Error reading input: %v", err)
 		}
 	case "help":
 		if len(os.Args) == 2 {
@@ -194,67 +188,6 @@ func getModuleNameForUpdater() (string, error) {
 	return bi.Main.Path, nil
 }
 
-// getGoModuleName reads the Go module name from the go.mod file in the current directory.
-// This is used for processing files.
-func getGoModuleName() (string, error) {
-	data, err := os.ReadFile("go.mod")
-	if err != nil {
-		return "", err
-	}
-	lines := strings.Split(string(data), "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if strings.HasPrefix(line, "module ") {
-			parts := strings.Fields(line)
-			if len(parts) >= 2 {
-				return parts[1], nil
-			}
-		}
-	}
-	return "", fmt.Errorf("module name not found in go.mod")
-}
-
-// getJavaModuleName attempts to extract the base package (group) from common Java build files.
-func getJavaModuleName() (string, error) {
-	if _, err := os.Stat("pom.xml"); err == nil {
-		data, err := os.ReadFile("pom.xml")
-		if err != nil {
-			return "", err
-		}
-		re := regexp.MustCompile(`<groupId>\s*([^<\s]+)\s*</groupId>`)
-		matches := re.FindSubmatch(data)
-		if len(matches) >= 2 {
-			return string(matches[1]), nil
-		}
-		return "", fmt.Errorf("groupId not found in pom.xml")
-	}
-	if _, err := os.Stat("build.gradle"); err == nil {
-		data, err := os.ReadFile("build.gradle")
-		if err != nil {
-			return "", err
-		}
-		re := regexp.MustCompile(`(?m)^\s*group\s*=\s*['"]([^'"]+)['"]`)
-		matches := re.FindSubmatch(data)
-		if len(matches) >= 2 {
-			return string(matches[1]), nil
-		}
-		return "", fmt.Errorf("group not found in build.gradle")
-	}
-	if _, err := os.Stat("build.gradle.kts"); err == nil {
-		data, err := os.ReadFile("build.gradle.kts")
-		if err != nil {
-			return "", err
-		}
-		re := regexp.MustCompile(`(?m)^\s*group\s*=\s*["']([^"']+)["']`)
-		matches := re.FindSubmatch(data)
-		if len(matches) >= 2 {
-			return string(matches[1]), nil
-		}
-		return "", fmt.Errorf("group not found in build.gradle.kts")
-	}
-	return "", fmt.Errorf("no recognized Java build file found")
-}
-
 // checkForUpdates queries GitHub for the latest release and prints a banner with release notes
 // if the current version is outdated. It derives the repository info from the module name.
 func checkForUpdates(moduleName string) {
@@ -312,429 +245,313 @@ func checkForUpdates(moduleName string) {
 }
 
 // isExcludedFile checks if the file (by its relative path) matches any exclusion pattern.
+// Patterns use forward slashes and may contain "**" to match any number of directories.
 func isExcludedFile(relPath string) bool {
+	relPath = filepath.ToSlash(relPath)
 	for _, pattern := range excludeFiles {
-		if match, err := filepath.Match(pattern, relPath); err == nil && match {
+		if matchPattern(filepath.ToSlash(filepath.Clean(pattern)), relPath) {
 			return true
 		}
 	}
 	return false
 }
 
-// processFile processes any file. For Go, Java, or Kotlin files, it handles them recursively.
-// The output is written to w.
-func processFile(filePath, moduleName string, processed map[string]bool, w io.Writer) error {
+// processFile writes a file to w and, if a resolver is registered for its
+// extension, recursively processes the files it depends on.
+func processFile(filePath string, processed map[string]bool, w io.Writer) error {
 	filePath = filepath.Clean(filePath)
-	if _, err := os.Stat(filePath); err != nil {
-		if os.IsNotExist(err) {
-			log.Printf("File not found: %s", filePath)
-			return nil
-		}
+	written, err := includeFile(filePath, processed, w)
+	if err != nil || !written {
 		return err
 	}
-	absPath, err := filepath.Abs(filePath)
+	deps, err := fileDependencies(filePath)
 	if err != nil {
 		return err
 	}
-	relPath, err := filepath.Rel(".", absPath)
-	if err != nil {
-		relPath = filePath
-	}
-	if isExcludedFile(relPath) {
-		return nil
-	}
-	if processed[absPath] {
-		return nil
-	}
-	processed[absPath] = true
-
-	switch filepath.Ext(filePath) {
-	case ".go":
-		if len(excludePackages) > 0 {
-			pkg, err := getGoPackageName(filePath)
-			if err != nil {
-				log.Printf("Warning: unable to determine package for %s: %v", filePath, err)
-			} else {
-				for _, ex := range excludePackages {
-					if pkg == ex {
-						return nil
-					}
-				}
-			}
+	for _, dep := range deps {
+		if err := processFile(dep, processed, w); err != nil {
+			log.Printf("Error processing %s: %v", dep, err)
 		}
-		return processGoFile(filePath, moduleName, processed, w)
-	case ".java":
-		return processJavaFile(filePath, javaBase, processed, w)
-	case ".kt", ".kts":
-		return processKotlinFile(filePath, javaBase, processed, w)
-	default:
-		return processNonSourceFile(filePath, w)
 	}
+	return nil
 }
 
-// getGoPackageName parses the Go file to extract its package declaration.
-func getGoPackageName(filePath string) (string, error) {
-	fset := token.NewFileSet()
-	f, err := parser.ParseFile(fset, filePath, nil, parser.PackageClauseOnly)
+// fileDependencies returns the files a file depends on according to the
+// resolver registered for its extension, if any.
+func fileDependencies(filePath string) ([]string, error) {
+	r, ok := resolverFor(filePath)
+	if !ok {
+		return nil, nil
+	}
+	data, err := os.ReadFile(filepath.Clean(filePath))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	return f.Name.Name, nil
+	return r.Dependencies(filePath, data)
 }
 
-// processGoFile processes a Go source file.
-func processGoFile(filePath, moduleName string, processed map[string]bool, w io.Writer) error {
-	filePath = filepath.Clean(filePath)
-	absPath, err := filepath.Abs(filePath)
-	if err != nil {
-		return err
+// includeFile writes a single file to w unless it is missing, excluded,
+// ignored or already processed, and reports whether it was written.
+func includeFile(filePath string, processed map[string]bool, w io.Writer) (bool, error) {
+	relPath, ok, err := acceptFile(filePath, processed)
+	if err != nil || !ok {
+		return false, err
 	}
-	relPath, err := filepath.Rel(".", absPath)
-	if err != nil {
-		relPath = filePath
-	}
-	info, err := os.Stat(filePath)
-	if err != nil {
-		return err
+	return true, writeFile(w, filePath, relPath)
+}
+
+// acceptFile decides whether a file becomes part of the bundle: it must
+// exist, must not be excluded, ignored or processed before, and Go files must
+// not belong to an excluded package. Accepted files are marked as processed.
+// It returns the path to write in the file's header.
+func acceptFile(filePath string, processed map[string]bool) (string, bool, error) {
+	filePath = filepath.Clean(filePath)
+	if _, err := os.Stat(filePath); err != nil {
+		if os.IsNotExist(err) {
+			log.Printf("File not found: %s", filePath)
+			return "", false, nil
+		}
+		return "", false, err
 	}
-	modTime := info.ModTime().Format(time.RFC3339)
-	fmt.Fprintf(w, fileStartFormat, relPath, info.Size(), modTime)
-	f, err := os.Open(filePath)
+	absPath, err := filepath.Abs(filePath)
 	if err != nil {
-		return err
-	}
-	if _, err := io.Copy(w, f); err != nil {
-		_ = f.Close()
-		return err
+		return "", false, err
 	}
-	if err := f.Close(); err != nil {
-		log.Printf("Error closing file %s: %v", filePath, err)
+	relPath := headerPath(filePath, absPath)
+	if isExcludedFile(relPath) || isIgnored(absPath, false) {
+		return "", false, nil
 	}
-	fmt.Fprintf(w, fileEndFormat, relPath)
-	f2, err := os.Open(filePath)
-	if err != nil {
-		return err
-	}
-	defer func() {
-		if cerr := f2.Close(); cerr != nil {
-			log.Printf("Error closing file %s: %v", filePath, cerr)
-		}
-	}()
-	fset := token.NewFileSet()
-	parsed, err := parser.ParseFile(fset, filePath, f2, parser.ImportsOnly)
-	if err != nil {
-		return err
+	if processed[absPath] {
+		return "", false, nil
 	}
-	for _, imp := range parsed.Imports {
-		importPath, err := strconv.Unquote(imp.Path.Value)
-		if err != nil {
-			continue
-		}
-		if !strings.HasPrefix(importPath, moduleName) {
-			continue
-		}
-		var relDir string
-		if importPath == moduleName {
-			relDir = "."
-		} else if strings.HasPrefix(importPath, moduleName+"/") {
-			relDir = strings.TrimPrefix(importPath, moduleName+"/")
-		} else {
-			continue
-		}
-		packageDir := filepath.Join(".", relDir)
-		packageDir = filepath.Clean(packageDir)
-		entries, err := os.ReadDir(packageDir)
-		if err != nil {
-			log.Printf("Error reading directory %q: %v", packageDir, err)
-			continue
-		}
-		for _, entry := range entries {
-			if entry.IsDir() {
-				continue
-			}
-			fileInPkg := filepath.Join(packageDir, entry.Name())
-			fileInPkg = filepath.Clean(fileInPkg)
-			if err := processFile(fileInPkg, moduleName, processed, w); err != nil {
-				log.Printf("Error processing %s: %v", fileInPkg, err)
-			}
-		}
+	processed[absPath] = true
+	if filepath.Ext(filePath) == ".go" && isExcludedPackage(filePath) {
+		return "", false, nil
 	}
-	return nil
+	return relPath, true, nil
 }
 
-// processJavaFile processes a Java source file.
-func processJavaFile(filePath, base string, processed map[string]bool, w io.Writer) error {
-	filePath = filepath.Clean(filePath)
+// writeFile writes the section of an accepted file, reduced to its reachable
+// declarations with -shake decls.
+func writeFile(w io.Writer, filePath, relPath string) error {
 	absPath, err := filepath.Abs(filePath)
 	if err != nil {
 		return err
 	}
-	relPath, err := filepath.Rel(".", absPath)
-	if err != nil {
-		relPath = filePath
-	}
-	info, err := os.Stat(filePath)
-	if err != nil {
-		return err
-	}
-	modTime := info.ModTime().Format(time.RFC3339)
-	fmt.Fprintf(w, fileStartFormat, relPath, info.Size(), modTime)
-	f, err := os.Open(filePath)
-	if err != nil {
-		return err
-	}
-	if _, err := io.Copy(w, f); err != nil {
-		_ = f.Close()
-		return err
+	if src, ok := shakenSource(absPath); ok {
+		return writeFileData(w, filePath, relPath, src, ", shaken: "+shakeDecls)
 	}
-	if err := f.Close(); err != nil {
-		log.Printf("Error closing file %s: %v", filePath, err)
+	return writeFileSection(w, filePath, relPath)
+}
+
+// isExcludedPackage reports whether the Go file belongs to a package named by
+// -exclude-packages.
+func isExcludedPackage(filePath string) bool {
+	if len(excludePackages) == 0 {
+		return false
 	}
-	fmt.Fprintf(w, fileEndFormat, relPath)
-	f2, err := os.Open(filePath)
+	pkg, err := getGoPackageName(filePath)
 	if err != nil {
-		return err
+		log.Printf("Warning: unable to determine package for %s: %v", filePath, err)
+		return false
 	}
-	defer f2.Close()
-	scanner := bufio.NewScanner(f2)
-	importRegex := regexp.MustCompile(`^\s*import\s+([a-zA-Z0-9_.]+);`)
-	for scanner.Scan() {
-		line := scanner.Text()
-		matches := importRegex.FindStringSubmatch(line)
-		if matches == nil {
-			continue
-		}
-		importPath := matches[1]
-		if importPath == base || strings.HasPrefix(importPath, base+".") {
-			var relDir string
-			if importPath == base {
-				relDir = "."
-			} else {
-				relDir = strings.TrimPrefix(importPath, base+".")
-				relDir = filepath.FromSlash(strings.ReplaceAll(relDir, ".", "/"))
-			}
-			packageDir := filepath.Join(".", relDir)
-			packageDir = filepath.Clean(packageDir)
-			entries, err := os.ReadDir(packageDir)
-			if err != nil {
-				log.Printf("Error reading directory %q: %v", packageDir, err)
-				continue
-			}
-			for _, entry := range entries {
-				if entry.IsDir() {
-					continue
-				}
-				if filepath.Ext(entry.Name()) != ".java" {
-					continue
-				}
-				fileInPkg := filepath.Join(packageDir, entry.Name())
-				fileInPkg = filepath.Clean(fileInPkg)
-				if err := processFile(fileInPkg, base, processed, w); err != nil {
-					log.Printf("Error processing %s: %v", fileInPkg, err)
-				}
-			}
+	for _, ex := range excludePackages {
+		if pkg == ex {
+			return true
 		}
 	}
-	if err := scanner.Err(); err != nil {
-		return err
-	}
-	return nil
+	return false
 }
 
-// processKotlinFile processes a Kotlin source file (.kt or .kts).
-func processKotlinFile(filePath, base string, processed map[string]bool, w io.Writer) error {
-	filePath = filepath.Clean(filePath)
-	absPath, err := filepath.Abs(filePath)
-	if err != nil {
-		return err
+// setBuildTarget configures the Go build context from -goos, -goarch and
+// -tags. As with go build, cgo is disabled for a target other than the host
+// unless CGO_ENABLED is set.
+func setBuildTarget(goos, goarch, tags string) {
+	if goos != "" {
+		buildContext.GOOS = strings.TrimSpace(goos)
 	}
-	relPath, err := filepath.Rel(".", absPath)
-	if err != nil {
-		relPath = filePath
+	if goarch != "" {
+		buildContext.GOARCH = strings.TrimSpace(goarch)
 	}
-	info, err := os.Stat(filePath)
-	if err != nil {
-		return err
+	if tags != "" {
+		for _, tag := range strings.Split(tags, ",") {
+			buildContext.BuildTags = append(buildContext.BuildTags, strings.TrimSpace(tag))
+		}
 	}
-	modTime := info.ModTime().Format(time.RFC3339)
-	fmt.Fprintf(w, fileStartFormat, relPath, info.Size(), modTime)
-	f, err := os.Open(filePath)
-	if err != nil {
-		return err
+	if os.Getenv("CGO_ENABLED") == "" && (buildContext.GOOS != runtime.GOOS || buildContext.GOARCH != runtime.GOARCH) {
+		buildContext.CgoEnabled = false
 	}
-	if _, err := io.Copy(w, f); err != nil {
-		_ = f.Close()
-		return err
+}
+
+// isBuildableGoFile reports whether the file name in dir is a Go source file that
+// is part of the package for the target platform and build tags. Test files are
+// only included when -tests is set.
+func isBuildableGoFile(dir, name string) bool {
+	if filepath.Ext(name) != ".go" {
+		return false
 	}
-	if err := f.Close(); err != nil {
-		log.Printf("Error closing file %s: %v", filePath, err)
+	if strings.HasSuffix(name, "_test.go") && !includeTests {
+		return false
 	}
-	fmt.Fprintf(w, fileEndFormat, relPath)
-	f2, err := os.Open(filePath)
+	match, err := buildContext.MatchFile(dir, name)
 	if err != nil {
-		return err
-	}
-	defer f2.Close()
-	scanner := bufio.NewScanner(f2)
-	importRegex := regexp.MustCompile(`^\s*import\s+([a-zA-Z0-9_.]+);?`)
-	for scanner.Scan() {
-		line := scanner.Text()
-		matches := importRegex.FindStringSubmatch(line)
-		if matches == nil {
-			continue
-		}
-		importPath := matches[1]
-		if importPath == base || strings.HasPrefix(importPath, base+".") {
-			var relDir string
-			if importPath == base {
-				relDir = "."
-			} else {
-				relDir = strings.TrimPrefix(importPath, base+".")
-				relDir = filepath.FromSlash(strings.ReplaceAll(relDir, ".", "/"))
-			}
-			packageDir := filepath.Join(".", relDir)
-			packageDir = filepath.Clean(packageDir)
-			entries, err := os.ReadDir(packageDir)
-			if err != nil {
-				log.Printf("Error reading directory %q: %v", packageDir, err)
-				continue
-			}
-			for _, entry := range entries {
-				if entry.IsDir() {
-					continue
-				}
-				ext := filepath.Ext(entry.Name())
-				if ext != ".kt" && ext != ".kts" {
-					continue
-				}
-				fileInPkg := filepath.Join(packageDir, entry.Name())
-				fileInPkg = filepath.Clean(fileInPkg)
-				if err := processFile(fileInPkg, base, processed, w); err != nil {
-					log.Printf("Error processing %s: %v", fileInPkg, err)
+		log.Printf("Warning: unable to evaluate build constraints for %s: %v", filepath.Join(dir, name), err)
+		return false
+	}
+	if match && !buildContext.CgoEnabled {
+		// Like go build, leave out files importing "C" when cgo is disabled.
+		f, err := parser.ParseFile(token.NewFileSet(), filepath.Join(dir, name), nil, parser.ImportsOnly)
+		if err == nil {
+			for _, imp := range f.Imports {
+				if imp.Path.Value == `"C"` {
+					return false
 				}
 			}
 		}
 	}
-	if err := scanner.Err(); err != nil {
-		return err
-	}
-	return nil
+	return match
 }
 
-// processNonSourceFile outputs a non-source file with header and footer delimiters.
-func processNonSourceFile(filePath string, w io.Writer) error {
-	filePath = filepath.Clean(filePath)
-	absPath, err := filepath.Abs(filePath)
-	if err != nil {
-		return err
+// headerPath returns the path of a file as written in its delimiters and matched
+// by -exclude-files: relative to the project root, or vendor/<module>/... for
+// files read from the module cache.
+func headerPath(filePath, absPath string) string {
+	if p, ok := moduleCachePath(absPath); ok {
+		return p
 	}
-	relPath, err := filepath.Rel(".", absPath)
+	relPath, err := filepath.Rel(rootDir, absPath)
 	if err != nil {
-		relPath = filePath
+		return filePath
 	}
-	info, err := os.Stat(filePath)
-	if err != nil {
-		return err
-	}
-	modTime := info.ModTime().Format(time.RFC3339)
-	fmt.Fprintf(w, fileStartFormat, relPath, info.Size(), modTime)
-	f, err := os.Open(filePath)
+	return relPath
+}
+
+// getGoPackageName parses the Go file to extract its package declaration.
+func getGoPackageName(filePath string) (string, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filePath, nil, parser.PackageClauseOnly)
 	if err != nil {
-		return err
-	}
-	if _, err := io.Copy(w, f); err != nil {
-		_ = f.Close()
-		return err
+		return "", err
 	}
-	if err := f.Close(); err != nil {
-		log.Printf("Error closing file %s: %v", filePath, err)
+	return f.Name.Name, nil
+}
+
+// openInput opens the named file, or STDIN if name is empty.
+func openInput(name string) (io.ReadCloser, error) {
+	if name == "" {
+		return io.NopCloser(os.Stdin), nil
 	}
-	fmt.Fprintf(w, fileEndFormat, relPath)
-	return nil
+	return os.Open(filepath.Clean(name))
 }
 
 // splitInput reads a joined stream and recreates each file based on the delimiters.
-func splitInput(r io.Reader, outDir string) error {
-	scanner := bufio.NewScanner(r)
-	if !scanner.Scan() {
-		return fmt.Errorf("input is empty, missing magic header")
-	}
-	firstLine := scanner.Text()
-	if !strings.HasPrefix(firstLine, magicHeader) {
-		return fmt.Errorf("invalid magic header: %s", firstLine)
+// File contents are reproduced byte for byte using the size recorded in each header.
+// Files that do not match their header are reported; in strict mode nothing is
+// written if any file does not match. If preserve is set, the file mode and
+// modification time recorded in each header are restored.
+func splitInput(r io.Reader, outDir string, strict, preserve bool) error {
+	entries, err := readBundle(r)
+	if err != nil {
+		return err
 	}
 	var absOutDir string
-	var err error
 	if outDir != "" {
 		absOutDir, err = filepath.Abs(filepath.Clean(outDir))
 		if err != nil {
 			return fmt.Errorf("failed to get absolute path for output directory: %v", err)
 		}
 	}
-	var currentFile *os.File
-	var currentFilename string
-	inFile := false
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.HasPrefix(line, fileStartPrefix) {
-			startQuote := strings.Index(line, "\"")
-			if startQuote == -1 {
-				log.Printf("Invalid header format: %s", line)
-				continue
-			}
-			endQuote := strings.Index(line[startQuote+1:], "\"")
-			if endQuote == -1 {
-				log.Printf("Invalid header format: %s", line)
-				continue
-			}
-			filename := line[startQuote+1 : startQuote+1+endQuote]
+	var damaged int
+	for _, entry := range entries {
+		if status := entry.status(); status != statusIntact && status != statusUnchecked {
+			log.Printf("Warning: %q is %s", entry.path, status)
+			damaged++
+		}
+	}
+	if strict && damaged > 0 {
+		return fmt.Errorf("%d file(s) do not match their headers", damaged)
+	}
+	for _, entry := range entries {
+		filename := filepath.Clean(entry.path)
+		if entry.damage != "" {
+			log.Printf("Cannot recover %q; skipping", entry.path)
+			continue
+		}
+		if absOutDir != "" {
+			filename = filepath.Join(absOutDir, filename)
 			filename = filepath.Clean(filename)
-			if absOutDir != "" {
-				filename = filepath.Join(absOutDir, filename)
-				filename = filepath.Clean(filename)
-				relToOut, err := filepath.Rel(absOutDir, filename)
-				if err != nil || strings.HasPrefix(relToOut, "..") {
-					log.Printf("Invalid output file path %q; skipping", filename)
-					continue
-				}
-			}
-			if err := os.MkdirAll(filepath.Dir(filename), 0750); err != nil {
-				log.Printf("Error creating directories for %q: %v", filename, err)
-				continue
-			}
-			f, err := os.Create(filename)
-			if err != nil {
-				log.Printf("Error creating file %q: %v", filename, err)
+			relToOut, err := filepath.Rel(absOutDir, filename)
+			if err != nil || strings.HasPrefix(relToOut, "..") {
+				log.Printf("Invalid output file path %q; skipping", filename)
 				continue
 			}
-			currentFile = f
-			currentFilename = filename
-			inFile = true
+		}
+		// Directories are shared by every file below them, so they get the
+		// conventional 0755 (subject to umask) rather than a per-file mode.
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil { // #nosec G301
+			log.Printf("Error creating directories for %q: %v", filename, err)
 			continue
 		}
-		if strings.HasPrefix(line, fileEndPrefix) && inFile {
-			if currentFile != nil {
-				if err := currentFile.Close(); err != nil {
-					log.Printf("Error closing file %q: %v", currentFilename, err)
-				}
-			}
-			currentFile = nil
-			currentFilename = ""
-			inFile = false
+		f, err := os.Create(filename)
+		if err != nil {
+			log.Printf("Error creating file %q: %v", filename, err)
 			continue
 		}
-		if inFile && currentFile != nil {
-			if _, err := currentFile.WriteString(line + "\n"); err != nil {
-				log.Printf("Error writing to file %q: %v", currentFilename, err)
-			}
+		if _, err := f.Write(entry.body); err != nil {
+			log.Printf("Error writing to file %q: %v", filename, err)
+		}
+		if err := f.Close(); err != nil {
+			log.Printf("Error closing file %q: %v", filename, err)
+		}
+		if preserve {
+			restoreMetadata(filename, entry)
 		}
-	}
-	if err := scanner.Err(); err != nil {
-		return err
 	}
 	return nil
 }
 
+// restoreMetadata applies the file mode and modification time recorded in the
+// header of entry to filename. Headers without these fields leave the file as
+// created.
+func restoreMetadata(filename string, entry *bundleEntry) {
+	if entry.mode != 0 {
+		if err := os.Chmod(filename, entry.mode); err != nil {
+			log.Printf("Error setting mode of %q: %v", filename, err)
+		}
+	}
+	if entry.modTime == "" {
+		return
+	}
+	modTime, err := time.Parse(time.RFC3339, entry.modTime)
+	if err != nil {
+		log.Printf("Invalid modtime %q for %q: %v", entry.modTime, entry.path, err)
+		return
+	}
+	if err := os.Chtimes(filename, modTime, modTime); err != nil {
+		log.Printf("Error setting modification time of %q: %v", filename, err)
+	}
+}
+
+// verifyInput checks every file in a joined stream against its header without
+// writing anything, and prints one status line per file to w. It reports
+// whether all files are intact.
+func verifyInput(r io.Reader, w io.Writer) (bool, error) {
+	entries, err := readBundle(r)
+	if err != nil {
+		return false, err
+	}
+	counts := make(map[string]int)
+	for _, entry := range entries {
+		status := entry.status()
+		counts[status]++
+		fmt.Fprintf(w, "%-18s %s\n", status, entry.path)
+	}
+	fmt.Fprintf(w, "\n%d file(s): %d intact, %d unchecked, %d modified, %d truncated, %d missing FILE END, %d invalid header\n",
+		len(entries), counts[statusIntact], counts[statusUnchecked], counts[statusModified],
+		counts[statusTruncated], counts[statusMissingEnd], counts[statusInvalid])
+	return counts[statusIntact]+counts[statusUnchecked] == len(entries), nil
+}
+
 // printGeneralHelp prints the general usage message with the version.
 func printGeneralHelp() {
 	fmt.Printf(`gocat %s
@@ -743,6 +560,8 @@ Usage: %s <command> [options] [arguments]
 Commands:
   join    Join source files (and their internal dependencies) into a single stream.
   split   Split a joined file into separate files.
+  verify  Check a joined file against its sizes and checksums.
+  stats   Report the files, languages and directories of a bundle by size and tokens.
   help    Show help information.
 
 For detailed help on a command, run:
@@ -755,33 +574,135 @@ For detailed help on a command, run:
 func printSubcommandHelp(cmd string) {
 	switch cmd {
 	case "join":
-		fmt.Printf(`Usage: %s join [file or glob pattern] ...
+		fmt.Printf(`Usage: %s join [file, directory or glob pattern] ...
 
 Joins the specified source file(s) into a single output stream,
 inserting delimiters between files. For Go files, the tool parses import statements
 and recursively includes files from packages within the same module (as determined by go.mod or -go-base).
-For Java/Kotlin files, if a base package is provided via -java-base (or auto-detected), recursive inclusion is performed
-by scanning for import statements.
+Imports of other modules in a go.work workspace, in nested go.mod files below the project
+root, or replaced with a local directory (replace example.com/x => ../x) are followed
+into those modules' directories.
+With -external, imports of third-party modules matching the given patterns are followed
+through vendor/ or the local module cache (GOMODCACHE, no network access), up to
+-max-depth import hops; files from the module cache are written as vendor/<module>/....
+Only Go files that build for the target platform are followed (see -goos, -goarch and -tags);
+as with go build, cgo files are left out for another platform unless CGO_ENABLED=1 is set.
+_test.go files are followed only with -tests.
+With -changed-since <ref>, the files added or modified since the merge base of <ref> and
+HEAD (including uncommitted changes and new untracked files that are not ignored) are
+joined together with the given files, using the git binary; -diff prepends the unified
+diff of the tracked files as a DIFF section, which split and verify skip.
+With -importers N (or -reverse N), the files importing the given files (for Go, any file
+of their package) are included as well, recursively up to N levels.
+With -max-tokens or -max-bytes, the given files are always included and their dependencies
+(and importers) follow in breadth-first order of their distance until the budget is spent;
+the remaining files are listed in a DROPPED FILES trailer. Tokens are estimated offline.
+With -shake files, only the files of followed Go packages that declare a top-level
+declaration reachable from the given files are included; with -shake decls, such files are
+reduced to their reachable declarations (marked "shaken: decls" in the header). Reachability
+is syntactic (including dot imports); reachable types keep their methods and reached
+packages their init functions and, unshaken, their cgo files.
+For Java/Kotlin files, imports are mapped to the exact class file in the project's source roots
+(src/main/java, src/test/java, src/main/kotlin, ... in the root and its submodules, or the
+directories given via -java-src, plus source directories configured in the build files).
+Wildcard imports include the whole package, static imports and nested-class imports
+resolve to the file of the declaring top-level class. Classes of the same package that a
+file refers to by simple name (and does not import from elsewhere) are included as well.
+Kotlin imports, including aliased imports and imports of top-level functions and
+properties, are resolved through an index of the declared package and top-level
+declarations of every Kotlin file, so files whose directory does not mirror their package
+are found too. Without source
+roots, imports within a base package (-java-base or auto-detected) are looked up relative to
+its module directory. Base packages and modules are detected from pom.xml (including parent
+POMs and <modules>) or from settings.gradle(.kts) subprojects and build.gradle(.kts) groups.
+For TypeScript/JavaScript files (.ts, .tsx, .js, .jsx, .mjs, ...), relative imports,
+require() calls and export ... from statements are followed with the usual extension and
+index file resolution; other specifiers are resolved through the paths and baseUrl of the
+nearest tsconfig.json. Packages in node_modules are not followed.
+For Python files, import and from ... import statements (absolute and relative) are
+resolved to modules and packages (__init__.py) below the project root, the script's own
+directory, or the package directories configured in pyproject.toml (such as src/).
+For Rust files, mod declarations and use paths starting with crate::, self:: or super:: are
+followed to the module files (name.rs or name/mod.rs). For C/C++ files, #include "..."
+directives are resolved relative to the including file and then in the -I directories
+(<...> includes only in the -I directories). For .proto files, imports are resolved in the
+-I directories and the project root.
 Non-source files are simply included as-is.
+Directories are walked recursively, and glob patterns may use "**" to match any
+number of directories (e.g. "./pkg/**/*.go"); -exclude-files accepts the same patterns.
+Files ignored by .gitignore files (from the top of the git work tree down, including
+nested ones) or a .gocatignore file in the project root are skipped unless -no-ignore
+is given.
+The project root is the nearest directory above the first argument (or the current
+directory) containing go.mod, pom.xml or build.gradle, or the nearest one holding its
+workspace (settings.gradle, a pom.xml with <modules>, or a go.work using the module),
+without leaving the git work tree; use -root to set it explicitly. Header paths are
+relative to it.
+Binary files (containing NUL bytes or invalid UTF-8) are base64 encoded by default;
+use -binary=skip to leave them out or -binary=raw to copy them unchanged.
 Each file is included only once.
 
 Example:
-  %s join "main.go" "./pkg/*.go" -exclude-packages="expressions,lexer" -exclude-files="vendor/*,testdata/*" -java-base="com.example" -go-base="github.com/example/project"
-`, "gocat", "gocat")
+  %s join "main.go" "./pkg/*.go" -exclude-packages="expressions,lexer" -exclude-files="vendor/**,**/testdata/**" -java-base="com.example" -go-base="github.com/example/project"
+  %s join internal/billing "./pkg/**/*.go"
+`, "gocat", "gocat", "gocat")
 	case "split":
-		fmt.Printf(`Usage: %s split [-in inputfile] [-out outputdirectory]
+		fmt.Printf(`Usage: %s split [-in inputfile] [-out outputdirectory] [-strict] [-no-preserve]
 
 Splits a joined file (or STDIN) into separate files using the inserted delimiters.
+Files whose content does not match the size or SHA-256 checksum in their header
+are reported with a warning; files whose header or encoded content cannot be read
+are not written. The file mode and modification time recorded in each
+header are restored; directories are created with mode 0755 (subject to umask).
 
 Options:
-  -in   Input file to split (if omitted, STDIN is used)
-  -out  Output directory for the extracted files (default: current directory)
+  -in           Input file to split (if omitted, STDIN is used)
+  -out          Output directory for the extracted files (default: current directory)
+  -strict       Fail without writing any file if a file does not match its header
+  -no-preserve  Do not restore file modes and modification times
 
 Examples:
   %s split -in joined.txt -out outputFolder
   %s split -out outputFolder < joined.txt>
 `, "gocat", "gocat", "gocat")
+	case "verify":
+		fmt.Printf(`Usage: %s verify [-in inputfile]
+
+Checks a joined file (or STDIN) without writing anything. Each file is reported as
+intact, modified (content does not match its checksum or cannot be decoded),
+truncated (shorter than its recorded size), missing FILE END, invalid header (a
+header or encoding that cannot be parsed), or unchecked (a gocat v1 header without
+a checksum). Exits with a non-zero status if any file is not intact or unchecked.
+
+Options:
+  -in   Input file to verify (if omitted, STDIN is used)
+
+Example:
+  %s verify -in joined.txt
+`, "gocat", "gocat")
+	case "stats":
+		fmt.Printf(`Usage: %s stats [-json] [-in inputfile]
+       %s stats [-json] [join options] [file, directory or glob pattern] ...
+
+Reports where the bytes of a bundle go: a table of its files with their language,
+lines, bytes and estimated tokens, totals per language, and a directory tree with
+the totals of each directory. Bytes are taken from the size field of each header.
+An OVERHEAD row counts the rest of the bundle (delimiters, base64 encoding, DIFF
+and DROPPED FILES sections), and the BUNDLE row the whole bundle, as wc -c does.
+Without files to join, an existing bundle is read from -in or STDIN. With files (or
+-changed-since), stats accepts the options of join and reports on the bundle join
+would write, without writing it.
+
+Options:
+  -in    Bundle to report on (if omitted and no files are given, STDIN is used)
+  -json  Print the report as JSON (files, languages, directories, total, overhead
+         and bundle)
+
+Examples:
+  %s stats -in joined.txt
+  %s stats -max-tokens 8000 ./cmd/server/main.go
+`, "gocat", "gocat", "gocat", "gocat")
 	default:
-		fmt.Printf("Unknown help topic %q. Available topics: join, split\n", cmd)
+		fmt.Printf("Unknown help topic %q. Available topics: join, split, verify, stats\n", cmd)
 	}
 }
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"go/build"
+	"runtime"
+	"testing"
+)
+
+func TestIsBuildableGoFile(t *testing.T) {
+	defer func(ctx build.Context, tests bool) {
+		buildContext, includeTests = ctx, tests
+	}(buildContext, includeTests)
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"a.go":         "package a\n",
+		"a_linux.go":   "package a\n",
+		"a_windows.go": "package a\n",
+		"a_arm64.go":   "package a\n",
+		"tagged.go":    "//go:build integration\n\npackage a\n",
+		"ignored.go":   "//go:build ignore\n\npackage a\n",
+		"a_test.go":    "package a\n",
+		"README.md":    "",
+	})
+	tests := []struct {
+		goos, goarch string
+		tags         []string
+		withTests    bool
+		want         []string
+	}{
+		{"linux", "amd64", nil, false, []string{"a.go", "a_linux.go"}},
+		{"windows", "arm64", nil, false, []string{"a.go", "a_windows.go", "a_arm64.go"}},
+		{"linux", "amd64", []string{"integration"}, true, []string{"a.go", "a_linux.go", "tagged.go", "a_test.go"}},
+	}
+	names := []string{"a.go", "a_linux.go", "a_windows.go", "a_arm64.go", "tagged.go", "ignored.go", "a_test.go", "README.md"}
+	for _, tt := range tests {
+		buildContext.GOOS, buildContext.GOARCH, buildContext.BuildTags = tt.goos, tt.goarch, tt.tags
+		includeTests = tt.withTests
+		want := make(map[string]bool)
+		for _, name := range tt.want {
+			want[name] = true
+		}
+		for _, name := range names {
+			if got := isBuildableGoFile(dir, name); got != want[name] {
+				t.Errorf("%s/%s tags %v tests %v: isBuildableGoFile(%q) = %v, want %v",
+					tt.goos, tt.goarch, tt.tags, tt.withTests, name, got, want[name])
+			}
+		}
+	}
+}
+
+func TestSetBuildTarget(t *testing.T) {
+	defer func(ctx build.Context) { buildContext = ctx }(buildContext)
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"cgo.go": "package a\n\nimport \"C\"\n"})
+	other := "windows"
+	if runtime.GOOS == "windows" {
+		other = "linux"
+	}
+	tests := []struct {
+		goos, cgoEnv string
+		want         bool
+	}{
+		{runtime.GOOS, "1", true},
+		{other, "", false},
+		{other, "1", true},
+	}
+	for _, tt := range tests {
+		buildContext = build.Default
+		buildContext.CgoEnabled = true
+		t.Setenv("CGO_ENABLED", tt.cgoEnv)
+		setBuildTarget(tt.goos, "", "")
+		if got := isBuildableGoFile(dir, "cgo.go"); got != tt.want {
+			t.Errorf("-goos %s, CGO_ENABLED=%q: isBuildableGoFile(cgo.go) = %v, want %v", tt.goos, tt.cgoEnv, got, tt.want)
+		}
+	}
+}
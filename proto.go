@@ -0,0 +1,40 @@
+package main
+
+import (
+	"path/filepath"
+	"regexp"
+)
+
+func init() {
+	registerResolver(protoResolver{})
+}
+
+// protoImportRegex matches Protocol Buffers import statements, including
+// public and weak imports.
+var protoImportRegex = regexp.MustCompile(`(?m)^\s*import\s+(?:public\s+|weak\s+)?["']([^"']+)["']\s*;`)
+
+// protoResolver follows the import statements of .proto files.
+type protoResolver struct{}
+
+// Extensions implements Resolver.
+func (protoResolver) Extensions() []string {
+	return []string{".proto"}
+}
+
+// Dependencies implements Resolver. Like protoc, imports are resolved against
+// the import paths: the include directories (-I) and the project root. The
+// importing file's directory is tried last for files written relative to it.
+func (protoResolver) Dependencies(filePath string, data []byte) ([]string, error) {
+	dirs := append(append([]string{}, includeDirs...), rootDir, filepath.Dir(filePath))
+	var files []string
+	for _, m := range protoImportRegex.FindAllSubmatch(data, -1) {
+		name := filepath.FromSlash(string(m[1]))
+		for _, dir := range dirs {
+			if p := filepath.Join(dir, name); isRegularFile(p) {
+				files = appendUnique(files, p)
+				break
+			}
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	// pythonRoots holds the import roots, found on first use by
+	// loadPythonRoots.
+	pythonRoots []string
+
+	pythonImportRegex = regexp.MustCompile(`^import\s+(.+)$`)
+	pythonFromRegex   = regexp.MustCompile(`^from\s+(\.*)([A-Za-z0-9_.]*)\s+import\s+(.+)$`)
+	// pyprojectRootRegex matches the package directories configured for
+	// setuptools (where = [...], package-dir = {"" = ...}), Poetry
+	// (from = ...) and Hatch (packages = ["src/pkg"]).
+	pyprojectRootRegex = regexp.MustCompile(`(?m)^\s*(?:where\s*=\s*\[([^\]]*)\]|package-dir\s*=\s*\{[^}]*["']{2}\s*[:=]\s*["']([^"']+)["'])|\bfrom\s*=\s*["']([^"']+)["']|^\s*packages\s*=\s*\[\s*(["'][^\]]*)\]`)
+)
+
+func init() {
+	registerResolver(pythonResolver{})
+}
+
+// pythonResolver follows the imports of Python files to project modules.
+type pythonResolver struct{}
+
+// Extensions implements Resolver.
+func (pythonResolver) Extensions() []string {
+	return []string{".py"}
+}
+
+// Dependencies implements Resolver.
+func (pythonResolver) Dependencies(filePath string, data []byte) ([]string, error) {
+	return resolvePythonImports(filePath, data), nil
+}
+
+// loadPythonRoots returns the directories absolute imports are resolved
+// against: the project root, and the package directories configured in its
+// pyproject.toml or, failing that, a src directory next to it (the "src
+// layout").
+func loadPythonRoots() []string {
+	if pythonRoots != nil {
+		return pythonRoots
+	}
+	pythonRoots = []string{filepath.Clean(rootDir)}
+	data, err := os.ReadFile(filepath.Join(rootDir, "pyproject.toml"))
+	if err != nil {
+		return pythonRoots
+	}
+	for _, m := range pyprojectRootRegex.FindAllSubmatch(data, -1) {
+		var dirs []string
+		switch {
+		case m[1] != nil:
+			for _, q := range quotedStringRegex.FindAllSubmatch(m[1], -1) {
+				dirs = append(dirs, string(q[1]))
+			}
+		case m[2] != nil:
+			dirs = append(dirs, string(m[2]))
+		case m[3] != nil:
+			dirs = append(dirs, string(m[3]))
+		case m[4] != nil:
+			// Hatch lists package paths; their parent is the import root.
+			for _, q := range quotedStringRegex.FindAllSubmatch(m[4], -1) {
+				if dir := filepath.Dir(filepath.FromSlash(string(q[1]))); dir != "." {
+					dirs = append(dirs, dir)
+				}
+			}
+		}
+		for _, dir := range dirs {
+			if p := filepath.Join(rootDir, filepath.FromSlash(dir)); isDirectory(p) {
+				pythonRoots = appendUnique(pythonRoots, p)
+			}
+		}
+	}
+	if src := filepath.Join(rootDir, "src"); len(pythonRoots) == 1 && isDirectory(src) {
+		pythonRoots = append(pythonRoots, src)
+	}
+	return pythonRoots
+}
+
+// isDirectory reports whether p exists and is a directory.
+func isDirectory(p string) bool {
+	info, err := os.Stat(p)
+	return err == nil && info.IsDir()
+}
+
+// resolvePythonImports returns the project files imported by the Python
+// source in data, located at filePath: the modules named by import and
+// from ... import statements (absolute or relative), the submodules imported
+// from packages, and the __init__.py files of the packages containing them.
+// Absolute imports are resolved against the script's own directory and the
+// import roots; modules that are not found there (the standard library and
+// installed packages) are skipped.
+func resolvePythonImports(filePath string, data []byte) []string {
+	dir := filepath.Dir(filePath)
+	roots := append([]string{dir}, loadPythonRoots()...)
+	var files []string
+	for _, stmt := range pythonStatements(data) {
+		if m := pythonImportRegex.FindStringSubmatch(stmt); m != nil {
+			for _, name := range splitPythonNames(m[1]) {
+				files = appendUnique(files, resolvePythonModule(roots, name)...)
+			}
+			continue
+		}
+		m := pythonFromRegex.FindStringSubmatch(stmt)
+		if m == nil {
+			continue
+		}
+		searchRoots := roots
+		module := m[2]
+		if level := len(m[1]); level > 0 {
+			base := dir
+			for i := 1; i < level; i++ {
+				base = filepath.Dir(base)
+			}
+			searchRoots = []string{base}
+			files = appendUnique(files, pythonPackageInit(base)...)
+		}
+		if module != "" {
+			files = appendUnique(files, resolvePythonModule(searchRoots, module)...)
+		}
+		for _, name := range splitPythonNames(m[3]) {
+			if name == "*" {
+				continue
+			}
+			// "from pkg import mod" may name a submodule rather than an
+			// attribute of the package.
+			submodule := name
+			if module != "" {
+				submodule = module + "." + name
+			}
+			files = appendUnique(files, resolvePythonModule(searchRoots, submodule)...)
+		}
+	}
+	return files
+}
+
+// resolvePythonModule returns the file of the dotted module name in the first
+// root containing it (name.py or name/__init__.py), preceded by the
+// __init__.py files of its parent packages.
+func resolvePythonModule(roots []string, name string) []string {
+	parts := strings.Split(name, ".")
+	for _, root := range roots {
+		modPath := filepath.Join(append([]string{root}, parts...)...)
+		var file string
+		if isRegularFile(modPath + ".py") {
+			file = modPath + ".py"
+		} else if isRegularFile(filepath.Join(modPath, "__init__.py")) {
+			file = filepath.Join(modPath, "__init__.py")
+		} else {
+			continue
+		}
+		var files []string
+		pkgDir := root
+		for _, part := range parts[:len(parts)-1] {
+			pkgDir = filepath.Join(pkgDir, part)
+			files = append(files, pythonPackageInit(pkgDir)...)
+		}
+		return append(files, file)
+	}
+	return nil
+}
+
+// pythonPackageInit returns the __init__.py file of a package directory, if
+// it has one.
+func pythonPackageInit(dir string) []string {
+	if init := filepath.Join(dir, "__init__.py"); isRegularFile(init) {
+		return []string{init}
+	}
+	return nil
+}
+
+// splitPythonNames splits the names of an import statement ("a.b as c, d" or
+// "(x, y as z)") into the imported names, dropping aliases.
+func splitPythonNames(list string) []string {
+	list = strings.Trim(strings.TrimSpace(list), "()")
+	var names []string
+	for _, item := range strings.Split(list, ",") {
+		fields := strings.Fields(item)
+		if len(fields) > 0 {
+			names = append(names, fields[0])
+		}
+	}
+	return names
+}
+
+// pythonStatements returns the logical lines of Python source that may hold
+// import statements, with comments removed and lines continued by a
+// backslash or open parentheses joined. Triple-quoted strings are skipped.
+func pythonStatements(data []byte) []string {
+	var stmts []string
+	var current strings.Builder
+	depth := 0
+	inDocstring := ""
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if inDocstring != "" {
+			if strings.Contains(line, inDocstring) {
+				inDocstring = ""
+			}
+			continue
+		}
+		trimmed := strings.TrimSpace(line)
+		if depth == 0 && current.Len() == 0 {
+			if quote := docstringStart(trimmed); quote != "" {
+				if strings.Count(trimmed, quote) < 2 {
+					inDocstring = quote
+				}
+				continue
+			}
+		}
+		if i := strings.Index(trimmed, "#"); i != -1 {
+			trimmed = strings.TrimSpace(trimmed[:i])
+		}
+		continued := strings.HasSuffix(trimmed, "\\")
+		trimmed = strings.TrimSuffix(trimmed, "\\")
+		current.WriteString(trimmed)
+		current.WriteByte(' ')
+		depth += strings.Count(trimmed, "(") - strings.Count(trimmed, ")")
+		if depth > 0 || continued {
+			continue
+		}
+		depth = 0
+		if stmt := strings.TrimSpace(current.String()); stmt != "" {
+			stmts = append(stmts, stmt)
+		}
+		current.Reset()
+	}
+	return stmts
+}
+
+// docstringStart returns the triple quote a line starts with, if any.
+func docstringStart(line string) string {
+	line = strings.TrimLeft(line, "rRbBuU")
+	for _, quote := range []string{`"""`, `'''`} {
+		if strings.HasPrefix(line, quote) {
+			return quote
+		}
+	}
+	return ""
+}
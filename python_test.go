@@ -0,0 +1,86 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPythonStatements(t *testing.T) {
+	src := `"""Module docstring.
+
+import fake_in_docstring
+"""
+import os, sys  # comment
+from .models import (
+    User,  # trailing comment
+    Group,
+)
+from pkg.sub import \
+    thing
+r'''raw docstring'''
+x = call(1,
+         2)
+def f():
+    '''
+    from fake import inside
+    '''
+    import json
+`
+	want := []string{
+		"import os, sys",
+		"from .models import ( User, Group, )",
+		"from pkg.sub import  thing",
+		"x = call(1, 2)",
+		"def f():",
+		"import json",
+	}
+	if got := pythonStatements([]byte(src)); !reflect.DeepEqual(got, want) {
+		t.Errorf("pythonStatements =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestSplitPythonNames(t *testing.T) {
+	tests := []struct {
+		list string
+		want []string
+	}{
+		{"a", []string{"a"}},
+		{"a.b as c, d", []string{"a.b", "d"}},
+		{"( x, y as z, )", []string{"x", "y"}},
+		{"*", []string{"*"}},
+	}
+	for _, tt := range tests {
+		if got := splitPythonNames(tt.list); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitPythonNames(%q) = %q, want %q", tt.list, got, tt.want)
+		}
+	}
+}
+
+func TestPythonImports(t *testing.T) {
+	dir := t.TempDir()
+	root, roots := rootDir, pythonRoots
+	t.Cleanup(func() { rootDir, pythonRoots = root, roots })
+	rootDir, pythonRoots = dir, nil
+	writeTree(t, dir, map[string]string{
+		"pyproject.toml":           "[tool.setuptools.packages.find]\nwhere = [\"src\"]\n",
+		"src/app/__init__.py":      "",
+		"src/app/main.py":          "",
+		"src/app/models.py":        "",
+		"src/app/db/__init__.py":   "",
+		"src/app/db/session.py":    "",
+		"src/app/util/__init__.py": "",
+		"src/app/util/text.py":     "",
+		"src/shared/__init__.py":   "",
+		"src/shared/config.py":     "",
+		"src/helpers.py":           "",
+	})
+	data := "import os\nimport shared.config as cfg\nfrom . import models\nfrom .db import session\n" +
+		"from .util.text import slug\nfrom helpers import *\nimport numpy\n"
+	got := javaDeps(t, pythonResolver{}, dir, "src/app/main.py", data)
+	want := []string{"src/app/__init__.py", "src/app/db/__init__.py", "src/app/db/session.py", "src/app/models.py",
+		"src/app/util/__init__.py", "src/app/util/text.py", "src/helpers.py", "src/shared/__init__.py",
+		"src/shared/config.py"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("dependencies = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Resolver finds the dependencies of the source files of one language.
+// Resolvers are registered for the file extensions they handle; join writes
+// every file it reaches and then follows the dependencies returned by the
+// resolver for its extension. Files without a resolver are included as-is.
+type Resolver interface {
+	// Extensions returns the file extensions handled by the resolver,
+	// including the leading dot.
+	Extensions() []string
+	// Dependencies returns the project files that the source file at
+	// filePath, whose content is data, depends on.
+	Dependencies(filePath string, data []byte) ([]string, error)
+}
+
+// resolvers maps file extensions to the registered resolvers.
+var resolvers = make(map[string]Resolver)
+
+// registerResolver registers r for each of its extensions. A later
+// registration for the same extension replaces the earlier one.
+func registerResolver(r Resolver) {
+	for _, ext := range r.Extensions() {
+		resolvers[strings.ToLower(ext)] = r
+	}
+}
+
+// resolverFor returns the resolver for the extension of filePath.
+func resolverFor(filePath string) (Resolver, bool) {
+	r, ok := resolvers[strings.ToLower(filepath.Ext(filePath))]
+	return r, ok
+}
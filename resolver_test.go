@@ -0,0 +1,114 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestResolverFor(t *testing.T) {
+	tests := []struct {
+		path string
+		want Resolver
+	}{
+		{"main.go", goResolver{}},
+		{"A.java", javaResolver{}},
+		{"build.gradle.kts", kotlinResolver{}},
+		{"app.tsx", typeScriptResolver{}},
+		{"script.py", pythonResolver{}},
+		{"lib.rs", rustResolver{}},
+		{"include/x.hpp", cResolver{}},
+		{"api.proto", protoResolver{}},
+	}
+	for _, tt := range tests {
+		if got, ok := resolverFor(tt.path); !ok || got != tt.want {
+			t.Errorf("resolverFor(%q) = %T, %v, want %T", tt.path, got, ok, tt.want)
+		}
+	}
+	if r, ok := resolverFor("README.md"); ok {
+		t.Errorf("resolverFor(README.md) = %T", r)
+	}
+}
+
+func TestExpandRustUse(t *testing.T) {
+	tests := []struct {
+		tree string
+		want []string
+	}{
+		{"crate::a::b", []string{"crate::a::b"}},
+		{"crate::a::*", []string{"crate::a::*"}},
+		{"crate::a::{b,c::D}", []string{"crate::a::b", "crate::a::c::D"}},
+		{"crate::a::{self,b}", []string{"crate::a", "crate::a::b"}},
+		{"crate::{a::{b,c},d::{e::{F,G}},}", []string{"crate::a::b", "crate::a::c", "crate::d::e::F", "crate::d::e::G"}},
+		{"super::{}", nil},
+		{"std::io::{self,Write}", []string{"std::io", "std::io::Write"}},
+	}
+	for _, tt := range tests {
+		if got := expandRustUse(tt.tree); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("expandRustUse(%q) = %q, want %q", tt.tree, got, tt.want)
+		}
+	}
+}
+
+func TestRustDependencies(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"Cargo.toml":             "[package]\nname = \"x\"\n",
+		"src/lib.rs":             "",
+		"src/net.rs":             "",
+		"src/net/http.rs":        "",
+		"src/net/tcp/mod.rs":     "",
+		"src/net/tcp/stream.rs":  "",
+		"src/util/mod.rs":        "",
+		"src/generated/proto.rs": "",
+		"src/config.rs":          "",
+	})
+	data := `// mod commented;
+mod net;
+pub(crate) mod util;
+#[path = "generated/proto.rs"]
+mod proto;
+use crate::net::{http, tcp::{self, stream::Stream as S}};
+use self::config::Config;
+use std::io;
+`
+	got := javaDeps(t, rustResolver{}, dir, "src/lib.rs", data)
+	want := []string{"src/config.rs", "src/generated/proto.rs", "src/net.rs", "src/net/http.rs",
+		"src/net/tcp/mod.rs", "src/net/tcp/stream.rs", "src/util/mod.rs"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("dependencies = %v, want %v", got, want)
+	}
+	got = javaDeps(t, rustResolver{}, dir, "src/net/tcp/stream.rs", "use super::super::http;\nuse super::Tcp;\n")
+	if want := []string{"src/net/http.rs"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("dependencies of stream.rs = %v, want %v", got, want)
+	}
+}
+
+func TestCIncludeAndProtoDependencies(t *testing.T) {
+	dir := t.TempDir()
+	root, dirs := rootDir, includeDirs
+	t.Cleanup(func() { rootDir, includeDirs = root, dirs })
+	rootDir = dir
+	writeTree(t, dir, map[string]string{
+		"src/main.c":                 "",
+		"src/local.h":                "",
+		"include/api/api.h":          "",
+		"include/local.h":            "",
+		"protos/common/types.proto":  "",
+		"protos/svc/service.proto":   "",
+		"protos/svc/sibling.proto":   "",
+		"third_party/google/x.proto": "",
+	})
+	includeDirs = parseIncludeDirs("include, protos,third_party")
+	got := javaDeps(t, cResolver{}, dir, "src/main.c",
+		"#include <stdio.h>\n#include \"local.h\"\n#  include <api/api.h>\n#include \"missing.h\"\n")
+	if want := []string{"include/api/api.h", "src/local.h"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("C dependencies = %v, want %v", got, want)
+	}
+	got = javaDeps(t, protoResolver{}, dir, "protos/svc/service.proto",
+		"syntax = \"proto3\";\nimport \"common/types.proto\";\nimport public 'google/x.proto';\n"+
+			"import weak \"sibling.proto\";\nimport \"google/protobuf/any.proto\";\n")
+	want := []string{"protos/common/types.proto", "protos/svc/sibling.proto", "third_party/google/x.proto"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("proto dependencies = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// importersDepth is the number of levels of importers (reverse dependencies)
+// of the given files to include (-importers, -reverse); 0 disables it.
+var importersDepth int
+
+// buildImporterIndex returns, for every file below root that another file
+// depends on, the absolute paths of the files depending on it. The index is
+// built once by running the registered resolvers over every source file below
+// root, so it covers every language gocat follows. For Go, a file importing a
+// package depends on every file of that package.
+func buildImporterIndex(root string) map[string][]string {
+	index := make(map[string][]string)
+	_ = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			log.Printf("Warning: skipping %s: %v", p, err)
+			return nil
+		}
+		if d.IsDir() {
+			if p != root && skipGoDir(p, d.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		r, ok := resolverFor(p)
+		if !ok || !d.Type().IsRegular() || isIgnored(p, false) {
+			return nil
+		}
+		if filepath.Ext(p) == ".go" && !isBuildableGoFile(filepath.Dir(p), d.Name()) {
+			return nil
+		}
+		data, err := os.ReadFile(filepath.Clean(p))
+		if err != nil {
+			return nil
+		}
+		deps, err := r.Dependencies(p, data)
+		if err != nil {
+			return nil
+		}
+		absPath, err := filepath.Abs(p)
+		if err != nil {
+			return nil
+		}
+		for _, dep := range deps {
+			absDep, err := filepath.Abs(dep)
+			if err != nil || absDep == absPath {
+				continue
+			}
+			index[absDep] = appendUnique(index[absDep], absPath)
+		}
+		return nil
+	})
+	for _, importers := range index {
+		sort.Strings(importers)
+	}
+	return index
+}
+
+// importerLevels returns the files depending on the given files, and the
+// files depending on those, up to importersDepth levels. Each level lists the
+// files first reached at that distance.
+func importerLevels(files []string) [][]string {
+	if importersDepth <= 0 {
+		return nil
+	}
+	index := buildImporterIndex(rootDir)
+	seen := make(map[string]bool)
+	var level []string
+	for _, file := range files {
+		if absPath, err := filepath.Abs(file); err == nil && !seen[absPath] {
+			seen[absPath] = true
+			level = append(level, absPath)
+		}
+	}
+	var levels [][]string
+	for depth := 0; depth < importersDepth && len(level) > 0; depth++ {
+		var next []string
+		for _, file := range level {
+			for _, importer := range index[file] {
+				if !seen[importer] {
+					seen[importer] = true
+					next = append(next, importer)
+				}
+			}
+		}
+		if len(next) > 0 {
+			levels = append(levels, next)
+		}
+		level = next
+	}
+	return levels
+}
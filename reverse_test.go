@@ -0,0 +1,51 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestImporterLevels(t *testing.T) {
+	resetGoModules(t)
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"go.mod":              "module example.com/m\n",
+		"core/core.go":        "package core\n",
+		"core/extra.go":       "package core\n",
+		"svc/svc.go":          "package svc\n\nimport \"example.com/m/core\"\n",
+		"svc/other.go":        "package svc\n",
+		"cmd/main.go":         "package main\n\nimport \"example.com/m/svc\"\n",
+		"cmd/ignored_test.go": "package main\n\nimport \"example.com/m/core\"\n",
+		"vendor/v/v.go":       "package v\n\nimport \"example.com/m/core\"\n",
+		"py/lib.py":           "",
+		"py/app.py":           "import lib\n",
+	})
+	root, depth, ignored := rootDir, importersDepth, ignore
+	t.Cleanup(func() { rootDir, importersDepth, ignore = root, depth, ignored })
+	rootDir, ignore = dir, nil
+	addGoModule("example.com/m", dir)
+	abs := func(names ...string) []string {
+		var paths []string
+		for _, name := range names {
+			paths = append(paths, filepath.Join(dir, filepath.FromSlash(name)))
+		}
+		return paths
+	}
+
+	importersDepth = 2
+	// A Go file importing a package depends on every file of it.
+	got := importerLevels(abs("core/extra.go", "py/lib.py"))
+	want := [][]string{abs("svc/svc.go", "py/app.py"), abs("cmd/main.go")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("importerLevels = %v, want %v", got, want)
+	}
+	importersDepth = 1
+	if got := importerLevels(abs("core/core.go")); !reflect.DeepEqual(got, [][]string{abs("svc/svc.go")}) {
+		t.Errorf("importerLevels with depth 1 = %v", got)
+	}
+	importersDepth = 0
+	if got := importerLevels(abs("core/core.go")); got != nil {
+		t.Errorf("importerLevels with depth 0 = %v", got)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// rootDir is the absolute project root. Module and build files are read from
+// it, dependencies are resolved relative to it, and header paths are written
+// relative to it. It is set via -root or discovered by findProjectRoot.
+var rootDir = "."
+
+// rootFallback is set when no project root was found and rootDir is only the
+// current directory. Such a root is not walked for modules or source roots.
+var rootFallback bool
+
+var (
+	// projectFiles mark the root of a single project.
+	projectFiles = []string{"go.mod", "pom.xml", "build.gradle", "build.gradle.kts"}
+	// workspaceFiles mark the root of a Gradle build of several projects.
+	// go.work files are checked by isWorkspace.
+	workspaceFiles = []string{"settings.gradle", "settings.gradle.kts"}
+)
+
+// findProjectRoot walks up from start and returns the nearest directory
+// containing a workspace of the project (see isWorkspace), or else the nearest
+// directory containing a project file (go.mod, pom.xml, build.gradle). The
+// walk stops at the top of the git work tree containing start. It returns
+// false if neither is found.
+func findProjectRoot(start string) (string, bool) {
+	dir, err := filepath.Abs(start)
+	if err != nil {
+		return "", false
+	}
+	top, inGit := gitWorkTree(dir)
+	project := ""
+	for {
+		if project == "" && hasAnyFile(dir, projectFiles) {
+			project = dir
+		}
+		if isWorkspace(dir, project) {
+			return dir, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir || (inGit && dir == top) {
+			break
+		}
+		dir = parent
+	}
+	return project, project != ""
+}
+
+// isWorkspace reports whether dir holds a workspace containing the project
+// in the directory project: a settings.gradle, a pom.xml with <modules>, or,
+// as for the go command, a go.work that uses the project's module (or any
+// go.work if no project was found yet).
+func isWorkspace(dir, project string) bool {
+	if hasAnyFile(dir, workspaceFiles) || isMavenAggregator(dir) {
+		return true
+	}
+	work, err := readGoWork(filepath.Join(dir, "go.work"))
+	if err != nil {
+		return false
+	}
+	if project == "" {
+		return true
+	}
+	for _, use := range work.Use {
+		useDir := filepath.FromSlash(use.Path)
+		if !filepath.IsAbs(useDir) {
+			useDir = filepath.Join(dir, useDir)
+		}
+		if filepath.Clean(useDir) == project {
+			return true
+		}
+	}
+	return false
+}
+
+// hasAnyFile reports whether dir contains any of the named files.
+func hasAnyFile(dir string, names []string) bool {
+	for _, name := range names {
+		if info, err := os.Stat(filepath.Join(dir, name)); err == nil && !info.IsDir() {
+			return true
+		}
+	}
+	return false
+}
+
+// discoverRoot determines the project root for the given join arguments:
+// the first argument that exists is used as the starting point, falling back
+// to the current directory. It reports false if no project or workspace file
+// was found and the current directory is returned as is.
+func discoverRoot(args []string) (string, bool) {
+	for _, arg := range args {
+		matches, err := expandArgument(arg)
+		if err != nil || len(matches) == 0 {
+			continue
+		}
+		if root, ok := findProjectRoot(filepath.Dir(matches[0])); ok {
+			return root, true
+		}
+		break
+	}
+	if root, ok := findProjectRoot("."); ok {
+		return root, true
+	}
+	if abs, err := filepath.Abs("."); err == nil {
+		return abs, false
+	}
+	return ".", false
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestDiscoverRoot(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"svc/go.mod":     "module svc\n",
+		"svc/pkg/a.go":   "package pkg\n",
+		"loose/file.txt": "",
+	})
+	if root, ok := discoverRoot([]string{filepath.Join(dir, "svc", "pkg", "a.go")}); !ok || root != filepath.Join(dir, "svc") {
+		t.Errorf("discoverRoot(svc/pkg/a.go) = %q, %v, want %q, true", root, ok, filepath.Join(dir, "svc"))
+	}
+	t.Chdir(filepath.Join(dir, "loose"))
+	if root, ok := discoverRoot([]string{"file.txt"}); ok {
+		t.Errorf("discoverRoot(file.txt) = %q, true, want no project root", root)
+	}
+}
+
+func TestFindProjectRoot(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"ws/go.work":                "go 1.21\n\nuse ./svc\n",
+		"ws/svc/go.mod":             "module svc\n",
+		"ws/svc/pkg/a.go":           "",
+		"outer/go.work":             "go 1.21\n",
+		"outer/work/svc/go.mod":     "module svc\n",
+		"outer/work/svc/main.go":    "",
+		"outer/work/loose/a.txt":    "",
+		"above/settings.gradle":     "",
+		"above/repo/.git/HEAD":      "",
+		"above/repo/svc/go.mod":     "module svc\n",
+		"nested/pom.xml":            "<project><modules><module>inner</module></modules></project>",
+		"nested/inner/pom.xml":      "<project><modules><module>core</module></modules></project>",
+		"nested/inner/core/pom.xml": "<project></project>",
+		"mvn/pom.xml":               "<project><modules><module>core</module></modules></project>",
+		"mvn/core/pom.xml":          "<project></project>",
+		"mvn/core/src/main/java/A":  "",
+		"gradle/settings.gradle":    "include 'app'\n",
+		"gradle/app/build.gradle":   "",
+		"single/build.gradle.kts":   "",
+		"single/src/main/kotlin/Ak": "",
+		"none/file.txt":             "",
+	})
+	tests := []struct {
+		start, want string
+		ok          bool
+	}{
+		{"ws/svc/pkg", "ws", true},
+		// A go.work that does not use the module is not its workspace.
+		{"outer/work/svc", "outer/work/svc", true},
+		{"outer/work/loose", "outer", true},
+		{"nested/inner/core", "nested/inner", true},
+		// The walk stops at the top of the git work tree.
+		{"above/repo/svc", "above/repo/svc", true},
+		{"mvn/core/src/main/java", "mvn", true},
+		{"gradle/app", "gradle", true},
+		{"single/src/main/kotlin", "single", true},
+		{"none", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := findProjectRoot(filepath.Join(dir, filepath.FromSlash(tt.start)))
+		want := ""
+		if tt.ok {
+			want = filepath.Join(dir, filepath.FromSlash(tt.want))
+		}
+		if ok != tt.ok || got != want {
+			t.Errorf("findProjectRoot(%q) = %q, %v, want %q, %v", tt.start, got, ok, want, tt.ok)
+		}
+	}
+}
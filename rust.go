@@ -0,0 +1,189 @@
+package main
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+func init() {
+	registerResolver(rustResolver{})
+}
+
+var (
+	// rustModRegex matches out-of-line module declarations (mod name;),
+	// with an optional #[path = "..."] attribute.
+	rustModRegex = regexp.MustCompile(`(?m)^\s*(?:#\[path\s*=\s*"([^"]+)"\]\s*)?(?:pub(?:\([^)]*\))?\s+)?mod\s+([A-Za-z_][A-Za-z0-9_]*)\s*;`)
+	// rustUseRegex matches use declarations.
+	rustUseRegex = regexp.MustCompile(`(?ms)^\s*(?:pub(?:\([^)]*\))?\s+)?use\s+([^;]+);`)
+	// rustAliasRegex matches the renames (as name) within a use tree.
+	rustAliasRegex = regexp.MustCompile(`\s+as\s+[A-Za-z_][A-Za-z0-9_]*`)
+	// rustCommentRegex matches line and block comments.
+	rustCommentRegex = regexp.MustCompile(`(?s)//[^\n]*|/\*.*?\*/`)
+)
+
+// rustResolver follows the module declarations (mod name;) of Rust files and
+// their use declarations of crate::, self:: and super:: paths to the files of
+// the modules involved.
+type rustResolver struct{}
+
+// Extensions implements Resolver.
+func (rustResolver) Extensions() []string {
+	return []string{".rs"}
+}
+
+// Dependencies implements Resolver.
+func (rustResolver) Dependencies(filePath string, data []byte) ([]string, error) {
+	src := rustCommentRegex.ReplaceAll(data, nil)
+	dir := filepath.Dir(filePath)
+	modDir := rustModuleDir(filePath)
+	var files []string
+	for _, m := range rustModRegex.FindAllSubmatch(src, -1) {
+		if len(m[1]) > 0 {
+			if p := filepath.Join(dir, filepath.FromSlash(string(m[1]))); isRegularFile(p) {
+				files = appendUnique(files, p)
+			}
+			continue
+		}
+		if file, ok := rustModuleFile(modDir, string(m[2])); ok {
+			files = appendUnique(files, file)
+		}
+	}
+	for _, m := range rustUseRegex.FindAllSubmatch(src, -1) {
+		tree := rustAliasRegex.ReplaceAllString(string(m[1]), "")
+		for _, usePath := range expandRustUse(strings.Join(strings.Fields(tree), "")) {
+			files = appendUnique(files, resolveRustPath(filePath, modDir, usePath)...)
+		}
+	}
+	return files, nil
+}
+
+// rustModuleDir returns the directory holding the submodules of the module
+// defined by filePath: the file's own directory for crate roots and mod.rs
+// files, and dir/<name> for dir/<name>.rs.
+func rustModuleDir(filePath string) string {
+	dir := filepath.Dir(filePath)
+	switch filepath.Base(filePath) {
+	case "main.rs", "lib.rs", "mod.rs":
+		return dir
+	}
+	return filepath.Join(dir, strings.TrimSuffix(filepath.Base(filePath), ".rs"))
+}
+
+// rustModuleFile returns the file of submodule name of the module whose
+// submodules live in modDir: modDir/name.rs or modDir/name/mod.rs.
+func rustModuleFile(modDir, name string) (string, bool) {
+	for _, candidate := range []string{filepath.Join(modDir, name+".rs"), filepath.Join(modDir, name, "mod.rs")} {
+		if isRegularFile(candidate) {
+			return candidate, true
+		}
+	}
+	return "", false
+}
+
+// expandRustUse expands the use tree of a use declaration (without
+// whitespace and aliases) into the paths it imports, e.g.
+// "crate::a::{b,c::D}" into "crate::a::b" and "crate::a::c::D".
+func expandRustUse(tree string) []string {
+	open := strings.Index(tree, "{")
+	if open == -1 || !strings.HasSuffix(tree, "}") {
+		return []string{strings.TrimSuffix(tree, "::")}
+	}
+	prefix := tree[:open]
+	var paths []string
+	depth := 0
+	start := open + 1
+	inner := tree[:len(tree)-1]
+	for i := open + 1; i <= len(inner); i++ {
+		if i < len(inner) {
+			switch inner[i] {
+			case '{':
+				depth++
+				continue
+			case '}':
+				depth--
+				continue
+			case ',':
+				if depth > 0 {
+					continue
+				}
+			default:
+				continue
+			}
+		}
+		if item := inner[start:i]; item != "" {
+			if item == "self" {
+				paths = append(paths, strings.TrimSuffix(prefix, "::"))
+			} else {
+				for _, p := range expandRustUse(item) {
+					paths = append(paths, prefix+p)
+				}
+			}
+		}
+		start = i + 1
+	}
+	return paths
+}
+
+// resolveRustPath returns the files of the modules along a crate::, self:: or
+// super:: path used from filePath, whose submodules live in modDir. Each
+// segment is resolved as a submodule of the previous one for as long as a
+// module file exists; the remaining segments name items. Paths into other
+// crates are not followed.
+func resolveRustPath(filePath, modDir, usePath string) []string {
+	segments := strings.Split(usePath, "::")
+	var dir string
+	switch segments[0] {
+	case "crate":
+		root, ok := rustCrateRoot(filePath)
+		if !ok {
+			return nil
+		}
+		dir = filepath.Dir(root)
+	case "self":
+		dir = modDir
+	case "super":
+		dir = modDir
+		for len(segments) > 0 && segments[0] == "super" {
+			dir = filepath.Dir(dir)
+			segments = segments[1:]
+		}
+		segments = append([]string{"super"}, segments...)
+	default:
+		return nil
+	}
+	var files []string
+	for _, name := range segments[1:] {
+		file, ok := rustModuleFile(dir, name)
+		if !ok {
+			break
+		}
+		files = append(files, file)
+		dir = rustModuleDir(file)
+	}
+	return files
+}
+
+// rustCrateRoot returns the root file (src/lib.rs or src/main.rs) of the
+// crate containing filePath, found through the nearest Cargo.toml.
+func rustCrateRoot(filePath string) (string, bool) {
+	dir, err := filepath.Abs(filepath.Dir(filePath))
+	if err != nil {
+		return "", false
+	}
+	for {
+		if isRegularFile(filepath.Join(dir, "Cargo.toml")) {
+			for _, name := range []string{"lib.rs", "main.rs"} {
+				if root := filepath.Join(dir, "src", name); isRegularFile(root) {
+					return root, true
+				}
+			}
+			return "", false
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
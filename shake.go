@@ -0,0 +1,475 @@
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Tree shaking modes for followed Go packages (-shake).
+const (
+	shakeOff   = "off"
+	shakeFile  = "files"
+	shakeDecls = "decls"
+)
+
+// shakeMode selects how much of a followed Go package is written: every file
+// (off), only the files declaring reachable symbols (files), or only the
+// reachable declarations (decls).
+var shakeMode = shakeOff
+
+// shakePackage is a followed Go package with its parsed files and an index of
+// its top-level declarations.
+type shakePackage struct {
+	dir   string
+	name  string
+	files []*shakeUnit
+	// decls maps top-level names to their declarations; methods maps
+	// receiver type names to their methods.
+	decls   map[string][]*shakeDecl
+	methods map[string][]*shakeDecl
+	reached bool
+}
+
+// shakeUnit is a parsed Go file of a followed package or an entry file.
+type shakeUnit struct {
+	path  string
+	data  []byte
+	fset  *token.FileSet
+	file  *ast.File
+	pkg   *shakePackage
+	decls []*shakeDecl
+	entry bool
+	// imports maps the local names of the file's imports to the followed
+	// packages, dots lists the followed packages imported with a dot, and
+	// names the local name of each followed import; all are filled when a
+	// declaration of the file is first reached.
+	imports map[string]*shakePackage
+	dots    []*shakePackage
+	names   map[*ast.ImportSpec]string
+}
+
+// shakeDecl is a top-level declaration and whether it is reachable.
+type shakeDecl struct {
+	unit    *shakeUnit
+	node    ast.Decl
+	reached bool
+}
+
+var (
+	// shakePackages holds the loaded packages by absolute directory; nil
+	// records a directory that could not be loaded.
+	shakePackages = make(map[string]*shakePackage)
+	// shakeUnits holds the parsed files by absolute path.
+	shakeUnits = make(map[string]*shakeUnit)
+)
+
+// shakeGo computes the top-level declarations of followed Go packages that
+// are reachable from the entry files. Reachability is syntactic: an
+// identifier reaches the declarations of that name in its own package and a
+// qualified identifier pkg.Name reaches Name in the imported package. A
+// reached type reaches all of its methods, since interface satisfaction is
+// not known without type checking, and a reached package always keeps its
+// init functions, its blank (var _ = ...) declarations, its cgo files and,
+// with -tests, its test files.
+func shakeGo(entries []string) {
+	var queue []*shakeDecl
+	for _, entry := range entries {
+		if filepath.Ext(entry) != ".go" {
+			continue
+		}
+		absPath, err := filepath.Abs(entry)
+		if err != nil {
+			continue
+		}
+		pkg := &shakePackage{dir: filepath.Dir(absPath), decls: make(map[string][]*shakeDecl), methods: make(map[string][]*shakeDecl)}
+		unit, err := parseShakeUnit(absPath, pkg)
+		if err != nil {
+			log.Printf("Warning: unable to parse %s for tree shaking: %v", entry, err)
+			continue
+		}
+		unit.entry = true
+		shakeUnits[absPath] = unit
+		queue = append(queue, unit.decls...)
+	}
+	for len(queue) > 0 {
+		d := queue[0]
+		queue = queue[1:]
+		if d.reached {
+			continue
+		}
+		d.reached = true
+		queue = append(queue, d.unit.pkg.reach()...)
+		queue = append(queue, d.references()...)
+	}
+}
+
+// reach marks the package as reached and returns the declarations that are
+// kept whenever the package is part of the bundle.
+func (p *shakePackage) reach() []*shakeDecl {
+	if p.reached {
+		return nil
+	}
+	p.reached = true
+	var roots []*shakeDecl
+	for _, unit := range p.files {
+		for _, d := range unit.decls {
+			if (includeTests && strings.HasSuffix(unit.path, "_test.go")) || unit.usesCgo() || isShakeRoot(d.node) {
+				roots = append(roots, d)
+			}
+		}
+	}
+	return roots
+}
+
+// isShakeRoot reports whether a declaration has effects without being
+// referenced: init functions and declarations of the blank identifier.
+func isShakeRoot(decl ast.Decl) bool {
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		return d.Recv == nil && d.Name.Name == "init"
+	case *ast.GenDecl:
+		for _, spec := range d.Specs {
+			if vs, ok := spec.(*ast.ValueSpec); ok {
+				for _, name := range vs.Names {
+					if name.Name == "_" {
+						return true
+					}
+				}
+			}
+		}
+	}
+	return false
+}
+
+// usesCgo reports whether the file imports "C". Its preamble, the comment of
+// the import, holds C code that the tree shaker cannot see into.
+func (u *shakeUnit) usesCgo() bool {
+	for _, spec := range u.file.Imports {
+		if spec.Path.Value == `"C"` {
+			return true
+		}
+	}
+	return false
+}
+
+// references returns the declarations referenced by d: the methods of the
+// types it declares, and the declarations named by its identifiers in its own
+// package or in a package the file imports with a dot.
+func (d *shakeDecl) references() []*shakeDecl {
+	unit := d.unit
+	pkg := unit.pkg
+	imports := unit.importMap()
+	var refs []*shakeDecl
+	for _, name := range declNames(d.node) {
+		refs = append(refs, pkg.methods[name]...)
+	}
+	ast.Inspect(d.node, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			if x, ok := n.X.(*ast.Ident); ok {
+				if imported := imports[x.Name]; imported != nil {
+					refs = append(refs, imported.decls[n.Sel.Name]...)
+					return false
+				}
+			}
+		case *ast.Ident:
+			refs = append(refs, pkg.decls[n.Name]...)
+			for _, dot := range unit.dots {
+				refs = append(refs, dot.decls[n.Name]...)
+			}
+		}
+		return true
+	})
+	return refs
+}
+
+// importMap returns the followed packages imported by the file by local name,
+// loading them on first use.
+func (u *shakeUnit) importMap() map[string]*shakePackage {
+	if u.imports != nil {
+		return u.imports
+	}
+	u.imports = make(map[string]*shakePackage)
+	u.names = make(map[*ast.ImportSpec]string)
+	depth := externalDepth[u.pkg.dir]
+	for _, spec := range u.file.Imports {
+		importPath, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		dir, ok := followGoImport(importPath, depth)
+		if !ok {
+			continue
+		}
+		imported := loadShakePackage(dir)
+		if imported == nil {
+			continue
+		}
+		name := imported.name
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		if name == "." {
+			u.dots = append(u.dots, imported)
+		} else {
+			u.imports[name] = imported
+		}
+		u.names[spec] = name
+	}
+	return u.imports
+}
+
+// loadShakePackage parses the buildable files of the package in dir.
+func loadShakePackage(dir string) *shakePackage {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil
+	}
+	if pkg, ok := shakePackages[absDir]; ok {
+		return pkg
+	}
+	shakePackages[absDir] = nil
+	entries, err := os.ReadDir(absDir)
+	if err != nil {
+		return nil
+	}
+	pkg := &shakePackage{dir: absDir, decls: make(map[string][]*shakeDecl), methods: make(map[string][]*shakeDecl)}
+	for _, entry := range entries {
+		if entry.IsDir() || !isBuildableGoFile(absDir, entry.Name()) {
+			continue
+		}
+		filePath := filepath.Join(absDir, entry.Name())
+		unit, err := parseShakeUnit(filePath, pkg)
+		if err != nil {
+			log.Printf("Warning: unable to parse %s for tree shaking: %v", filePath, err)
+			continue
+		}
+		if pkg.name == "" && !strings.HasSuffix(unit.file.Name.Name, "_test") {
+			pkg.name = unit.file.Name.Name
+		}
+		if _, ok := shakeUnits[filePath]; !ok {
+			shakeUnits[filePath] = unit
+		}
+	}
+	shakePackages[absDir] = pkg
+	return pkg
+}
+
+// parseShakeUnit parses a Go file and adds its declarations to pkg.
+func parseShakeUnit(filePath string, pkg *shakePackage) (*shakeUnit, error) {
+	data, err := os.ReadFile(filepath.Clean(filePath))
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filePath, data, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	unit := &shakeUnit{path: filePath, data: data, fset: fset, file: file, pkg: pkg}
+	for _, node := range file.Decls {
+		if gen, ok := node.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+			continue
+		}
+		d := &shakeDecl{unit: unit, node: node}
+		unit.decls = append(unit.decls, d)
+		if fn, ok := node.(*ast.FuncDecl); ok && fn.Recv != nil {
+			if recv := receiverType(fn); recv != "" {
+				pkg.methods[recv] = append(pkg.methods[recv], d)
+			}
+			continue
+		}
+		for _, name := range declNames(node) {
+			pkg.decls[name] = append(pkg.decls[name], d)
+		}
+	}
+	pkg.files = append(pkg.files, unit)
+	return unit, nil
+}
+
+// declNames returns the names declared by a top-level declaration other than
+// a method.
+func declNames(decl ast.Decl) []string {
+	var names []string
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		if d.Recv == nil {
+			names = append(names, d.Name.Name)
+		}
+	case *ast.GenDecl:
+		for _, spec := range d.Specs {
+			switch s := spec.(type) {
+			case *ast.TypeSpec:
+				names = append(names, s.Name.Name)
+			case *ast.ValueSpec:
+				for _, name := range s.Names {
+					names = append(names, name.Name)
+				}
+			}
+		}
+	}
+	return names
+}
+
+// receiverType returns the name of the receiver's base type of a method.
+func receiverType(fn *ast.FuncDecl) string {
+	if len(fn.Recv.List) == 0 {
+		return ""
+	}
+	expr := fn.Recv.List[0].Type
+	for {
+		switch t := expr.(type) {
+		case *ast.StarExpr:
+			expr = t.X
+		case *ast.ParenExpr:
+			expr = t.X
+		case *ast.IndexExpr:
+			expr = t.X
+		case *ast.IndexListExpr:
+			expr = t.X
+		case *ast.Ident:
+			return t.Name
+		default:
+			return ""
+		}
+	}
+}
+
+// shakeKeepFile reports whether a file of a followed Go package is written.
+// Files not seen by the tree shaker (for example because they failed to
+// parse) and the cgo files of reached packages are kept.
+func shakeKeepFile(filePath string) bool {
+	if shakeMode == shakeOff {
+		return true
+	}
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		return true
+	}
+	unit, ok := shakeUnits[absPath]
+	if !ok || (unit.pkg.reached && unit.usesCgo()) {
+		return true
+	}
+	for _, d := range unit.decls {
+		if d.reached {
+			return true
+		}
+	}
+	return false
+}
+
+// shakenSource returns the content of a file of a followed Go package reduced
+// to its reachable declarations, in decls mode. It reports false if the file
+// is written unchanged: entry files, files not seen by the tree shaker, cgo
+// files and files whose declarations are all reachable.
+func shakenSource(absPath string) ([]byte, bool) {
+	if shakeMode != shakeDecls {
+		return nil, false
+	}
+	unit, ok := shakeUnits[absPath]
+	if !ok || unit.entry || unit.usesCgo() {
+		return nil, false
+	}
+	var kept []*shakeDecl
+	for _, d := range unit.decls {
+		if d.reached {
+			kept = append(kept, d)
+		}
+	}
+	if len(kept) == len(unit.decls) {
+		return nil, false
+	}
+	offset := func(pos token.Pos) int {
+		return unit.fset.Position(pos).Offset
+	}
+	// Keep the imports used by the kept declarations, and those imported
+	// for their side effects.
+	used := make(map[string]bool)
+	for _, d := range kept {
+		ast.Inspect(d.node, func(n ast.Node) bool {
+			if sel, ok := n.(*ast.SelectorExpr); ok {
+				if x, ok := sel.X.(*ast.Ident); ok {
+					used[x.Name] = true
+				}
+			}
+			return true
+		})
+	}
+	var imports []string
+	for _, spec := range unit.file.Imports {
+		if name := importName(unit, spec); name == "_" || name == "." || used[name] {
+			imports = append(imports, string(unit.data[offset(spec.Pos()):offset(spec.End())]))
+		}
+	}
+	var buf bytes.Buffer
+	buf.Write(unit.data[:offset(unit.file.Name.End())])
+	buf.WriteString("\n")
+	switch len(imports) {
+	case 0:
+	case 1:
+		buf.WriteString("\nimport " + imports[0] + "\n")
+	default:
+		buf.WriteString("\nimport (\n")
+		for _, imp := range imports {
+			buf.WriteString("\t" + imp + "\n")
+		}
+		buf.WriteString(")\n")
+	}
+	for _, d := range kept {
+		start := d.node.Pos()
+		if doc := declDoc(d.node); doc != nil {
+			start = doc.Pos()
+		}
+		buf.WriteString("\n")
+		buf.Write(unit.data[offset(start):offset(d.node.End())])
+		buf.WriteString("\n")
+	}
+	return buf.Bytes(), true
+}
+
+// importName returns the local name of an import in the file: its explicit
+// name, the name of the followed package, or the last element of the import
+// path.
+func importName(unit *shakeUnit, spec *ast.ImportSpec) string {
+	if spec.Name != nil {
+		return spec.Name.Name
+	}
+	unit.importMap()
+	if name, ok := unit.names[spec]; ok {
+		return name
+	}
+	importPath, err := strconv.Unquote(spec.Path.Value)
+	if err != nil {
+		return ""
+	}
+	name := importPath[strings.LastIndex(importPath, "/")+1:]
+	if strings.HasPrefix(name, "v") && len(name) > 1 && strings.Trim(name[1:], "0123456789") == "" {
+		// Major version suffixes (example.com/mod/v2) are not part of the
+		// package name.
+		if i := strings.LastIndex(importPath[:len(importPath)-len(name)-1], "/"); i != -1 {
+			name = importPath[i+1 : len(importPath)-len(name)-1]
+		}
+	}
+	if i := strings.IndexAny(name, ".-"); i != -1 {
+		name = name[:i]
+	}
+	return name
+}
+
+// declDoc returns the doc comment of a declaration.
+func declDoc(decl ast.Decl) *ast.CommentGroup {
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		return d.Doc
+	case *ast.GenDecl:
+		return d.Doc
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// setShakeProject writes a Go module below a temporary directory, registers
+// it and runs the tree shaker in decls mode from its main.go.
+func setShakeProject(t *testing.T, files map[string]string) string {
+	t.Helper()
+	resetGoModules(t)
+	dir := t.TempDir()
+	writeTree(t, dir, files)
+	mode, packages, units, ctx := shakeMode, shakePackages, shakeUnits, buildContext
+	t.Cleanup(func() { shakeMode, shakePackages, shakeUnits, buildContext = mode, packages, units, ctx })
+	shakeMode, shakePackages, shakeUnits = shakeDecls, make(map[string]*shakePackage), make(map[string]*shakeUnit)
+	buildContext.CgoEnabled = true
+	addGoModule("example.com/m", dir)
+	shakeGo([]string{filepath.Join(dir, "main.go")})
+	return dir
+}
+
+func TestShakeDecls(t *testing.T) {
+	dir := setShakeProject(t, map[string]string{
+		"main.go": "package main\n\nimport \"example.com/m/lib\"\n\nfunc main() { lib.Used(lib.T{}) }\n",
+		"lib/lib.go": "package lib\n\nimport (\n\t\"fmt\"\n\t\"strings\"\n)\n\n" +
+			"// Used is reached from main.\nfunc Used(t T) { fmt.Println(t.M()) }\n\n" +
+			"// Unused is not.\nfunc Unused() string { return strings.ToUpper(\"x\") }\n\n" +
+			"type T struct{}\n\nfunc (T) M() string { return helper }\n\nvar helper = \"h\"\n\n" +
+			"func init() { register() }\n\nfunc register() {}\n",
+		"lib/other.go": "package lib\n\nfunc Other() {}\n",
+	})
+	lib := filepath.Join(dir, "lib", "lib.go")
+	src, ok := shakenSource(lib)
+	if !ok {
+		t.Fatal("lib.go was not shaken")
+	}
+	for _, want := range []string{"// Used is reached", "func Used", "type T struct", "func (T) M", "var helper",
+		"func init", "func register", "\"fmt\""} {
+		if !strings.Contains(string(src), want) {
+			t.Errorf("shaken lib.go lacks %q:\n%s", want, src)
+		}
+	}
+	for _, unwanted := range []string{"Unused", "\"strings\""} {
+		if strings.Contains(string(src), unwanted) {
+			t.Errorf("shaken lib.go contains %q:\n%s", unwanted, src)
+		}
+	}
+	if shakeKeepFile(filepath.Join(dir, "lib", "other.go")) {
+		t.Error("other.go declares nothing reachable but is kept")
+	}
+}
+
+func TestShakeDotImport(t *testing.T) {
+	dir := setShakeProject(t, map[string]string{
+		"main.go":    "package main\n\nimport . \"example.com/m/dot\"\n\nfunc main() { Hello() }\n",
+		"dot/dot.go": "package dot\n\nfunc Hello() { helper() }\n\nfunc helper() {}\n\nfunc Unused() {}\n",
+		"dot/x.go":   "package dot\n\nfunc X() {}\n",
+	})
+	src, ok := shakenSource(filepath.Join(dir, "dot", "dot.go"))
+	if !ok || !strings.Contains(string(src), "func Hello") || !strings.Contains(string(src), "func helper") ||
+		strings.Contains(string(src), "Unused") {
+		t.Errorf("shaken dot.go = %q, %v; want Hello and helper only", src, ok)
+	}
+	if shakeKeepFile(filepath.Join(dir, "dot", "x.go")) {
+		t.Error("x.go declares nothing reachable but is kept")
+	}
+}
+
+func TestShakeKeepsCgoFiles(t *testing.T) {
+	cgo := "package cg\n\n/*\nint add(int a, int b) { return a + b; }\n*/\nimport \"C\"\n\n" +
+		"func F() int { return int(C.add(1, 2)) }\n\nfunc Unused() {}\n"
+	dir := setShakeProject(t, map[string]string{
+		"main.go":        "package main\n\nimport \"example.com/m/cg\"\n\nfunc main() { cg.G() }\n",
+		"cg/cg.go":       cgo,
+		"cg/preamble.go": "package cg\n\n// static int counter;\nimport \"C\"\n",
+		"cg/g.go":        "package cg\n\nfunc G() {}\n\nfunc H() {}\n",
+	})
+	for _, name := range []string{"cg.go", "preamble.go"} {
+		p := filepath.Join(dir, "cg", name)
+		if !shakeKeepFile(p) {
+			t.Errorf("cgo file %s is dropped", name)
+		}
+		if src, ok := shakenSource(p); ok {
+			t.Errorf("cgo file %s is shaken:\n%s", name, src)
+		}
+	}
+	if src, ok := shakenSource(filepath.Join(dir, "cg", "g.go")); !ok || strings.Contains(string(src), "func H") {
+		t.Errorf("g.go is not shaken: %q, %v", src, ok)
+	}
+}
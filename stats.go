@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// languageNames maps file extensions to the language reported by stats.
+var languageNames = map[string]string{
+	".go": "Go", ".java": "Java", ".kt": "Kotlin", ".kts": "Kotlin",
+	".ts": "TypeScript", ".tsx": "TypeScript", ".mts": "TypeScript", ".cts": "TypeScript",
+	".js": "JavaScript", ".jsx": "JavaScript", ".mjs": "JavaScript", ".cjs": "JavaScript",
+	".py": "Python", ".rs": "Rust", ".c": "C", ".h": "C",
+	".cc": "C++", ".cpp": "C++", ".cxx": "C++", ".c++": "C++", ".hh": "C++", ".hpp": "C++", ".hxx": "C++", ".h++": "C++", ".inl": "C++", ".ipp": "C++",
+	".proto": "Protocol Buffers", ".md": "Markdown", ".json": "JSON", ".yaml": "YAML", ".yml": "YAML",
+	".xml": "XML", ".toml": "TOML", ".gradle": "Gradle", ".sql": "SQL", ".sh": "Shell",
+	".html": "HTML", ".css": "CSS",
+}
+
+// fileStats is the size of one file of a bundle, or the total of a group of
+// files.
+type fileStats struct {
+	Path     string `json:"path,omitempty"`
+	Language string `json:"language,omitempty"`
+	Files    int    `json:"files,omitempty"`
+	Lines    int    `json:"lines"`
+	Bytes    int64  `json:"bytes"`
+	Tokens   int    `json:"tokens"`
+}
+
+// bundleStats is the report printed by stats. Total is the sum of the files;
+// Overhead is everything else in the bundle (the magic header, delimiter
+// lines, the encoding of base64 files, the DIFF section and the DROPPED FILES
+// trailer), so that Total and Overhead add up to Bundle, the bundle itself.
+type bundleStats struct {
+	Files       []fileStats `json:"files"`
+	Languages   []fileStats `json:"languages"`
+	Directories []fileStats `json:"directories"`
+	Total       fileStats   `json:"total"`
+	Overhead    fileStats   `json:"overhead"`
+	Bundle      fileStats   `json:"bundle"`
+}
+
+// add adds the size of a file to a total.
+func (s *fileStats) add(f fileStats) {
+	s.Files++
+	s.Lines += f.Lines
+	s.Bytes += f.Bytes
+	s.Tokens += f.Tokens
+}
+
+// languageOf returns the language of a file by its extension.
+func languageOf(name string) string {
+	if lang, ok := languageNames[strings.ToLower(path.Ext(name))]; ok {
+		return lang
+	}
+	return "Other"
+}
+
+// collectStats computes the per-file, per-language and per-directory sizes of
+// the entries of a bundle, and the size of the bundle data as a whole. Bytes
+// are the file sizes recorded in the headers; tokens are estimated for the
+// content as it appears in the bundle (base64 text for encoded files, whose
+// lines are not counted).
+func collectStats(entries []*bundleEntry, data []byte) *bundleStats {
+	stats := &bundleStats{Files: []fileStats{}, Languages: []fileStats{}, Directories: []fileStats{}}
+	languages := make(map[string]*fileStats)
+	dirs := make(map[string]*fileStats)
+	for _, entry := range entries {
+		name := path.Clean(strings.ReplaceAll(entry.path, "\\", "/"))
+		f := fileStats{Path: name, Language: languageOf(name), Bytes: entry.size}
+		if f.Bytes < 0 {
+			f.Bytes = int64(len(entry.body))
+		}
+		if entry.encoding == "base64" && entry.damage == "" {
+			f.Tokens = estimateTokens(encodeBase64Lines(entry.body))
+		} else {
+			f.Tokens = estimateTokens(entry.body)
+			f.Lines = countLines(entry.body)
+		}
+		stats.Files = append(stats.Files, f)
+		stats.Total.add(f)
+		if languages[f.Language] == nil {
+			languages[f.Language] = &fileStats{Language: f.Language}
+		}
+		languages[f.Language].add(f)
+		for dir := path.Dir(name); ; dir = path.Dir(dir) {
+			if dirs[dir] == nil {
+				dirs[dir] = &fileStats{Path: dir}
+			}
+			dirs[dir].add(f)
+			if dir == "." || dir == "/" {
+				break
+			}
+		}
+	}
+	stats.Bundle = fileStats{Lines: countLines(data), Bytes: int64(len(data)), Tokens: estimateTokens(data)}
+	stats.Overhead = fileStats{
+		Lines:  stats.Bundle.Lines - stats.Total.Lines,
+		Bytes:  stats.Bundle.Bytes - stats.Total.Bytes,
+		Tokens: stats.Bundle.Tokens - stats.Total.Tokens,
+	}
+	sort.SliceStable(stats.Files, func(i, j int) bool { return stats.Files[i].Bytes > stats.Files[j].Bytes })
+	for _, lang := range languages {
+		stats.Languages = append(stats.Languages, *lang)
+	}
+	sort.Slice(stats.Languages, func(i, j int) bool {
+		if stats.Languages[i].Bytes != stats.Languages[j].Bytes {
+			return stats.Languages[i].Bytes > stats.Languages[j].Bytes
+		}
+		return stats.Languages[i].Language < stats.Languages[j].Language
+	})
+	for _, dir := range dirs {
+		stats.Directories = append(stats.Directories, *dir)
+	}
+	sort.Slice(stats.Directories, func(i, j int) bool {
+		return dirSortKey(stats.Directories[i].Path) < dirSortKey(stats.Directories[j].Path)
+	})
+	return stats
+}
+
+// countLines returns the number of lines in data, counting a final line
+// without a newline.
+func countLines(data []byte) int {
+	lines := bytes.Count(data, []byte("\n"))
+	if len(data) > 0 && needsSeparator(data) {
+		lines++
+	}
+	return lines
+}
+
+// dirSortKey orders directories depth-first with "." first, so that a
+// directory is followed by its subdirectories.
+func dirSortKey(dir string) string {
+	if dir == "." {
+		return ""
+	}
+	return strings.ReplaceAll(dir, "/", "\x00") + "\x00"
+}
+
+// statsInput reads a bundle from r and writes its size report to w, as
+// tables or, if asJSON is set, as JSON.
+func statsInput(r io.Reader, w io.Writer, asJSON bool) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	entries, err := readBundle(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	stats := collectStats(entries, data)
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(stats)
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "FILE\tLANGUAGE\tLINES\tBYTES\tTOKENS")
+	for _, f := range stats.Files {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t~%d\n", f.Path, f.Language, f.Lines, f.Bytes, f.Tokens)
+	}
+	fmt.Fprintf(tw, "TOTAL\t%d file(s)\t%d\t%d\t~%d\n", stats.Total.Files, stats.Total.Lines, stats.Total.Bytes, stats.Total.Tokens)
+	fmt.Fprintf(tw, "OVERHEAD\tdelimiters\t%d\t%d\t~%d\n", stats.Overhead.Lines, stats.Overhead.Bytes, stats.Overhead.Tokens)
+	fmt.Fprintf(tw, "BUNDLE\t\t%d\t%d\t~%d\n", stats.Bundle.Lines, stats.Bundle.Bytes, stats.Bundle.Tokens)
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "LANGUAGE\tFILES\tLINES\tBYTES\tTOKENS")
+	for _, l := range stats.Languages {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t~%d\n", l.Language, l.Files, l.Lines, l.Bytes, l.Tokens)
+	}
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "DIRECTORY\tFILES\tLINES\tBYTES\tTOKENS")
+	for _, d := range stats.Directories {
+		name := d.Path + "/"
+		if d.Path != "." {
+			name = strings.Repeat("  ", strings.Count(d.Path, "/")+1) + path.Base(d.Path) + "/"
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t~%d\n", name, d.Files, d.Lines, d.Bytes, d.Tokens)
+	}
+	return tw.Flush()
+}
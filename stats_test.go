@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestCountLines(t *testing.T) {
+	tests := []struct {
+		data string
+		want int
+	}{
+		{"", 0},
+		{"a", 1},
+		{"a\n", 1},
+		{"a\nb", 2},
+		{"\n\n", 2},
+		{"a\r\nb\r\n", 2},
+	}
+	for _, tt := range tests {
+		if got := countLines([]byte(tt.data)); got != tt.want {
+			t.Errorf("countLines(%q) = %d, want %d", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestCollectStats(t *testing.T) {
+	bundle := joinFiles(t, []string{"main.go", "pkg/a.go", "pkg/b.txt", "bin.dat"}, map[string][]byte{
+		"main.go":   []byte("package main\n\nfunc main() {}\n"),
+		"pkg/a.go":  []byte("package pkg"),
+		"pkg/b.txt": []byte("one\ntwo\nthree\n"),
+		"bin.dat":   {0, 1, 2, 3, 4, 5},
+	})
+	entries, err := readBundle(bytes.NewReader(bundle))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := collectStats(entries, bundle)
+	if got, want := stats.Total, (fileStats{Files: 4, Lines: 7, Bytes: 60}); got.Files != want.Files || got.Lines != want.Lines || got.Bytes != want.Bytes {
+		t.Errorf("total = %+v, want %+v", got, want)
+	}
+	if stats.Bundle.Bytes != int64(len(bundle)) || stats.Bundle.Lines != strings.Count(string(bundle), "\n") {
+		t.Errorf("bundle = %+v, want %d bytes and %d lines", stats.Bundle, len(bundle), strings.Count(string(bundle), "\n"))
+	}
+	if stats.Bundle.Tokens != estimateTokens(bundle) {
+		t.Errorf("bundle tokens = %d, want %d", stats.Bundle.Tokens, estimateTokens(bundle))
+	}
+	sum := stats.Total
+	sum.add(stats.Overhead)
+	if sum.Lines != stats.Bundle.Lines || sum.Bytes != stats.Bundle.Bytes || sum.Tokens != stats.Bundle.Tokens {
+		t.Errorf("total %+v plus overhead %+v != bundle %+v", stats.Total, stats.Overhead, stats.Bundle)
+	}
+	if stats.Files[0].Path != "main.go" || stats.Files[len(stats.Files)-1].Path != "bin.dat" {
+		t.Errorf("files are not sorted by bytes: %+v", stats.Files)
+	}
+	var dirs []string
+	for _, d := range stats.Directories {
+		dirs = append(dirs, d.Path)
+	}
+	if got := strings.Join(dirs, ","); got != ".,pkg" {
+		t.Errorf("directories = %s, want .,pkg", got)
+	}
+}
+
+func TestStatsInput(t *testing.T) {
+	bundle := joinFiles(t, []string{"a.go"}, map[string][]byte{"a.go": []byte("package a\n")})
+	bundle = append(bundle, "// --------- DROPPED FILES: 1 (budget: 10 tokens) ----------\n"+
+		"// \"b.go\" (section: 200 bytes, tokens: ~60, distance: 1)\n"+
+		"// --------- DROPPED FILES END ----------\n"...)
+	var out bytes.Buffer
+	if err := statsInput(bytes.NewReader(bundle), &out, true); err != nil {
+		t.Fatal(err)
+	}
+	var stats bundleStats
+	if err := json.Unmarshal(out.Bytes(), &stats); err != nil {
+		t.Fatalf("%v:\n%s", err, out.String())
+	}
+	if stats.Total.Bytes != 10 || stats.Bundle.Bytes != int64(len(bundle)) || stats.Overhead.Bytes != int64(len(bundle))-10 {
+		t.Errorf("total %+v, overhead %+v, bundle %+v for a %d-byte bundle", stats.Total, stats.Overhead, stats.Bundle, len(bundle))
+	}
+	out.Reset()
+	if err := statsInput(bytes.NewReader(bundle), &out, false); err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range []string{"TOTAL", "OVERHEAD", "BUNDLE"} {
+		if !strings.Contains(out.String(), "\n"+row+" ") {
+			t.Errorf("report has no %s row:\n%s", row, out.String())
+		}
+	}
+}
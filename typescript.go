@@ -0,0 +1,287 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// tsResolveExts are the extensions tried, in order, for an import specifier
+// without one, and for the index file of an imported directory.
+var tsResolveExts = []string{".ts", ".tsx", ".d.ts", ".js", ".jsx", ".mjs", ".cjs"}
+
+// tsImportRegex matches the module specifiers of import and export
+// declarations (import x from "y", import "y", export * from "y"), dynamic
+// import() calls and require() calls.
+var tsImportRegex = regexp.MustCompile(`(?:\bfrom|\bimport|\bimport\s*\(|\brequire\s*\()\s*['"]([^'"\n]+)['"]`)
+
+// tsConfig holds the module resolution settings of a tsconfig.json file.
+type tsConfig struct {
+	// baseURL is the absolute directory non-relative specifiers are resolved
+	// against, or "" if baseUrl is not set.
+	baseURL string
+	// pathsDir is the absolute directory the targets of paths are relative to.
+	pathsDir string
+	paths    map[string][]string
+}
+
+// tsConfigs caches the tsconfig.json files found per directory; a nil entry
+// records that a directory has none.
+var tsConfigs = make(map[string]*tsConfig)
+
+// tsConfigFile is the raw JSON of the tsconfig.json fields gocat uses.
+type tsConfigFile struct {
+	Extends         string `json:"extends"`
+	CompilerOptions struct {
+		BaseURL *string             `json:"baseUrl"`
+		Paths   map[string][]string `json:"paths"`
+	} `json:"compilerOptions"`
+}
+
+func init() {
+	registerResolver(typeScriptResolver{})
+}
+
+// typeScriptResolver follows the imports of TypeScript and JavaScript files.
+type typeScriptResolver struct{}
+
+// Extensions implements Resolver.
+func (typeScriptResolver) Extensions() []string {
+	return []string{".ts", ".tsx", ".mts", ".cts", ".js", ".jsx", ".mjs", ".cjs"}
+}
+
+// Dependencies implements Resolver.
+func (typeScriptResolver) Dependencies(filePath string, data []byte) ([]string, error) {
+	return resolveTypeScriptImports(filePath, data), nil
+}
+
+// resolveTypeScriptImports returns the files imported by the TypeScript or
+// JavaScript source in data, located at filePath. Relative specifiers are
+// resolved against the file's directory; other specifiers are resolved
+// through the paths and baseUrl of the nearest tsconfig.json. Packages from
+// node_modules are never followed.
+func resolveTypeScriptImports(filePath string, data []byte) []string {
+	dir := filepath.Dir(filePath)
+	var files []string
+	for _, m := range tsImportRegex.FindAllSubmatch(data, -1) {
+		spec := string(m[1])
+		if file, ok := resolveTypeScriptSpecifier(dir, spec); ok {
+			files = appendUnique(files, file)
+		}
+	}
+	return files
+}
+
+// resolveTypeScriptSpecifier resolves a single module specifier imported from
+// a file in dir.
+func resolveTypeScriptSpecifier(dir, spec string) (string, bool) {
+	if spec == "." || spec == ".." || strings.HasPrefix(spec, "./") || strings.HasPrefix(spec, "../") {
+		return resolveTypeScriptPath(filepath.Join(dir, filepath.FromSlash(spec)))
+	}
+	if strings.HasPrefix(spec, "/") {
+		return resolveTypeScriptPath(filepath.FromSlash(spec))
+	}
+	config := loadTSConfig(dir)
+	if config == nil {
+		return "", false
+	}
+	if pattern, star, ok := bestTSPathPattern(config.paths, spec); ok {
+		for _, target := range config.paths[pattern] {
+			target = strings.Replace(target, "*", star, 1)
+			if file, ok := resolveTypeScriptPath(filepath.Join(config.pathsDir, filepath.FromSlash(target))); ok {
+				return file, true
+			}
+		}
+	}
+	if config.baseURL != "" {
+		return resolveTypeScriptPath(filepath.Join(config.baseURL, filepath.FromSlash(spec)))
+	}
+	return "", false
+}
+
+// bestTSPathPattern returns the paths pattern tsc uses for spec, and the text
+// matched by its wildcard: a pattern without a wildcard equal to spec, or else
+// the matching pattern with the longest prefix before its "*". Ties, which tsc
+// breaks by declaration order, are broken by the longer and then the
+// lexically smaller pattern so that the choice does not depend on map order.
+func bestTSPathPattern(paths map[string][]string, spec string) (string, string, bool) {
+	best, bestStar, bestPrefix := "", "", -1
+	for pattern := range paths {
+		star, ok := matchTSPathPattern(pattern, spec)
+		if !ok {
+			continue
+		}
+		prefix, _, wildcard := strings.Cut(pattern, "*")
+		if !wildcard {
+			return pattern, "", true
+		}
+		switch {
+		case len(prefix) > bestPrefix,
+			len(prefix) == bestPrefix && len(pattern) > len(best),
+			len(prefix) == bestPrefix && len(pattern) == len(best) && pattern < best:
+			best, bestStar, bestPrefix = pattern, star, len(prefix)
+		}
+	}
+	return best, bestStar, bestPrefix >= 0
+}
+
+// matchTSPathPattern matches spec against a tsconfig paths pattern, which may
+// contain a single "*" wildcard, and returns the text matched by the wildcard.
+func matchTSPathPattern(pattern, spec string) (string, bool) {
+	prefix, suffix, ok := strings.Cut(pattern, "*")
+	if !ok {
+		return "", pattern == spec
+	}
+	if len(spec) < len(prefix)+len(suffix) || !strings.HasPrefix(spec, prefix) || !strings.HasSuffix(spec, suffix) {
+		return "", false
+	}
+	return spec[len(prefix) : len(spec)-len(suffix)], true
+}
+
+// resolveTypeScriptPath applies the module resolution rules to a path: the
+// file itself, the path with each extension appended, or the index file of
+// the directory. As TypeScript allows, a ".js" extension may name the ".ts"
+// source it is compiled from.
+func resolveTypeScriptPath(p string) (string, bool) {
+	if isRegularFile(p) {
+		return p, true
+	}
+	for _, ext := range tsResolveExts {
+		if isRegularFile(p + ext) {
+			return p + ext, true
+		}
+	}
+	switch ext := filepath.Ext(p); ext {
+	case ".js", ".jsx", ".mjs", ".cjs":
+		base := strings.TrimSuffix(p, ext)
+		sources := map[string][]string{".js": {".ts", ".tsx"}, ".jsx": {".tsx"}, ".mjs": {".mts"}, ".cjs": {".cts"}}
+		for _, source := range sources[ext] {
+			if isRegularFile(base + source) {
+				return base + source, true
+			}
+		}
+	}
+	for _, ext := range tsResolveExts {
+		index := filepath.Join(p, "index"+ext)
+		if isRegularFile(index) {
+			return index, true
+		}
+	}
+	return "", false
+}
+
+// isRegularFile reports whether p exists and is not a directory.
+func isRegularFile(p string) bool {
+	info, err := os.Stat(p)
+	return err == nil && !info.IsDir()
+}
+
+// loadTSConfig returns the settings of the nearest tsconfig.json in dir or its
+// parent directories up to the project root, or nil if there is none.
+func loadTSConfig(dir string) *tsConfig {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil
+	}
+	if config, ok := tsConfigs[absDir]; ok {
+		return config
+	}
+	var config *tsConfig
+	configPath := filepath.Join(absDir, "tsconfig.json")
+	if isRegularFile(configPath) {
+		config = readTSConfig(configPath, make(map[string]bool))
+	} else if absRoot, err := filepath.Abs(rootDir); err == nil && absDir != absRoot && filepath.Dir(absDir) != absDir {
+		config = loadTSConfig(filepath.Dir(absDir))
+	}
+	tsConfigs[absDir] = config
+	return config
+}
+
+// readTSConfig reads a tsconfig.json file, applying the settings of the
+// configuration it extends (if that is a relative path).
+func readTSConfig(configPath string, seen map[string]bool) *tsConfig {
+	if seen[configPath] {
+		return nil
+	}
+	seen[configPath] = true
+	data, err := os.ReadFile(filepath.Clean(configPath))
+	if err != nil {
+		return nil
+	}
+	var raw tsConfigFile
+	if err := json.Unmarshal(stripJSONC(data), &raw); err != nil {
+		return nil
+	}
+	dir := filepath.Dir(configPath)
+	config := &tsConfig{pathsDir: dir}
+	if strings.HasPrefix(raw.Extends, ".") {
+		parent := filepath.Join(dir, filepath.FromSlash(raw.Extends))
+		if filepath.Ext(parent) != ".json" {
+			parent += ".json"
+		}
+		if base := readTSConfig(parent, seen); base != nil {
+			config = base
+		}
+	}
+	if raw.CompilerOptions.BaseURL != nil {
+		config.baseURL = filepath.Join(dir, filepath.FromSlash(*raw.CompilerOptions.BaseURL))
+		config.pathsDir = config.baseURL
+	}
+	if raw.CompilerOptions.Paths != nil {
+		config.paths = raw.CompilerOptions.Paths
+		// Without a baseUrl, even an inherited one, paths are relative to the
+		// file declaring them.
+		if config.baseURL == "" {
+			config.pathsDir = dir
+		}
+	}
+	return config
+}
+
+// stripJSONC removes the comments and trailing commas tsconfig.json files may
+// contain, leaving plain JSON.
+func stripJSONC(data []byte) []byte {
+	var out []byte
+	inString := false
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case inString:
+			out = append(out, c)
+			if c == '\\' && i+1 < len(data) {
+				i++
+				out = append(out, data[i])
+			} else if c == '"' {
+				inString = false
+			}
+		case c == '"':
+			inString = true
+			out = append(out, c)
+		case c == '/' && i+1 < len(data) && data[i+1] == '/':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+			i--
+		case c == '/' && i+1 < len(data) && data[i+1] == '*':
+			i += 2
+			for i+1 < len(data) && (data[i] != '*' || data[i+1] != '/') {
+				i++
+			}
+			i++
+		case c == ']' || c == '}':
+			j := len(out) - 1
+			for j >= 0 && strings.ContainsRune(" \t\r\n", rune(out[j])) {
+				j--
+			}
+			if j >= 0 && out[j] == ',' {
+				out = append(out[:j], out[j+1:]...)
+			}
+			out = append(out, c)
+		default:
+			out = append(out, c)
+		}
+	}
+	return out
+}
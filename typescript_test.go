@@ -0,0 +1,101 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestStripJSONC(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{`{"a": 1}`, `{"a": 1}`},
+		{"{\"a\": 1, // comment\n\"b\": 2}", "{\"a\": 1, \n\"b\": 2}"},
+		{`{"a": /* inline */ 1}`, `{"a":  1}`},
+		{"{\"a\": [1, 2,\n],\n}", "{\"a\": [1, 2\n]\n}"},
+		{`{"url": "http://x/*y*/", "s": "a\"//b"}`, `{"url": "http://x/*y*/", "s": "a\"//b"}`},
+		{`{"p": ["a/*", ], }`, `{"p": ["a/*" ] }`},
+		{"// only a comment", ""},
+		{"{\"a\": 1 /* unterminated", "{\"a\": 1 "},
+	}
+	for _, tt := range tests {
+		if got := string(stripJSONC([]byte(tt.in))); got != tt.want {
+			t.Errorf("stripJSONC(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMatchTSPathPattern(t *testing.T) {
+	tests := []struct {
+		pattern, spec, star string
+		ok                  bool
+	}{
+		{"@app/*", "@app/x/y", "x/y", true},
+		{"@app/*", "@apps/x", "", false},
+		{"*", "anything", "anything", true},
+		{"@app", "@app", "", true},
+		{"@app", "@app/x", "", false},
+		{"*.css", "theme.css", "theme", true},
+		{"a*a", "a", "", false},
+	}
+	for _, tt := range tests {
+		star, ok := matchTSPathPattern(tt.pattern, tt.spec)
+		if star != tt.star || ok != tt.ok {
+			t.Errorf("matchTSPathPattern(%q, %q) = %q, %v, want %q, %v", tt.pattern, tt.spec, star, ok, tt.star, tt.ok)
+		}
+	}
+}
+
+func TestTypeScriptImports(t *testing.T) {
+	dir := t.TempDir()
+	root, configs := rootDir, tsConfigs
+	t.Cleanup(func() { rootDir, tsConfigs = root, configs })
+	rootDir, tsConfigs = dir, make(map[string]*tsConfig)
+	writeTree(t, dir, map[string]string{
+		"tsconfig.base.json": `{"compilerOptions": {"baseUrl": ".", "paths": {
+			// Catch-all for type declarations.
+			"*": ["types/*"],
+			"@app/*": ["src/app/*"],
+			"@app/special": ["src/special"],
+		}}}`,
+		"tsconfig.json":                 `{"extends": "./tsconfig.base"}`,
+		"src/main.ts":                   "",
+		"src/util.ts":                   "",
+		"src/lib/index.tsx":             "",
+		"src/compiled.ts":               "",
+		"src/app/x.ts":                  "",
+		"src/special.ts":                "",
+		"types/@app/x/index.d.ts":       "",
+		"types/@app/special/index.d.ts": "",
+		"types/untyped/index.d.ts":      "",
+		"src/plain.js":                  "",
+		// paths of an extending config resolve against the inherited baseUrl.
+		"pkg/tsconfig.json": `{"extends": "../tsconfig.base.json", "compilerOptions": {"paths": {"#shared/*": ["src/app/*"]}}}`,
+	})
+	data := `import { a } from "./util";
+import Lib from './lib';
+export * from "./compiled.js";
+const p = import("./plain.js");
+const r = require("@app/x");
+import "@app/special";
+import type { U } from "untyped";
+import React from "react";
+`
+	got := javaDeps(t, typeScriptResolver{}, dir, "src/main.ts", data)
+	want := []string{"src/app/x.ts", "src/compiled.ts", "src/lib/index.tsx", "src/plain.js", "src/special.ts",
+		"src/util.ts", "types/untyped/index.d.ts"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("dependencies = %v, want %v", got, want)
+	}
+	if file, ok := resolveTypeScriptSpecifier(filepath.Join(dir, "pkg"), "#shared/x"); !ok || file != filepath.Join(dir, "src", "app", "x.ts") {
+		t.Errorf("resolveTypeScriptSpecifier(#shared/x) = %q, %v, want src/app/x.ts", file, ok)
+	}
+	// The choice between matching patterns must not depend on map order.
+	for i := 0; i < 20; i++ {
+		file, ok := resolveTypeScriptSpecifier(filepath.Join(dir, "src"), "@app/x")
+		if want := filepath.Join(dir, "src", "app", "x.ts"); !ok || file != want {
+			t.Fatalf("resolveTypeScriptSpecifier(@app/x) = %q, want %q", file, want)
+		}
+	}
+}